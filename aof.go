@@ -0,0 +1,232 @@
+package goresp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy decides when an AOF forces its writes to disk, like appendfsync of redis
+type FsyncPolicy int
+
+const (
+	// FsyncEverySec syncs once per second in the background, a crash loses at most the last second of commands
+	FsyncEverySec FsyncPolicy = iota
+	// FsyncAlways syncs before Append returns, the slowest and safest
+	FsyncAlways
+	// FsyncNo leaves the flushing to the operating system and only syncs on Close
+	FsyncNo
+)
+
+type AOFOptions struct {
+	Fsync FsyncPolicy // when to sync the file, the zero value is FsyncEverySec like the default of redis
+}
+
+// AOF is an append only file of commands, the server logs every command that modifies its data
+// and replays the file with ReplayAOF on startup to rebuild it
+type AOF struct {
+	file *os.File
+	opts AOFOptions
+
+	mu      sync.Mutex
+	writer  *Writer
+	dirty   bool  // written to since the last sync
+	syncErr error // the failure of a background sync, returned by the next Append or Close
+	closed  bool
+
+	stop chan struct{} // closed by Close to end the background sync
+	done chan struct{}
+}
+
+// OpenAOF opens the file at path for appending, it is created if it doesn't exist
+func OpenAOF(path string) (*AOF, error) {
+	return OpenAOFWithOptions(path, AOFOptions{})
+}
+
+func OpenAOFWithOptions(path string, opts AOFOptions) (*AOF, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AOF{file: file, opts: opts, writer: NewWriter(file), stop: make(chan struct{}), done: make(chan struct{})}
+	if opts.Fsync == FsyncEverySec {
+		go a.syncLoop()
+	} else {
+		close(a.done)
+	}
+	return a, nil
+}
+
+// Append logs commands like NewSetValue, NewHsetValue or NewDelValue return, in order
+// with FsyncAlways they are on disk when it returns
+// the commands are logged all or nothing, when a write fails the file is cut back to its size before the call
+func (a *AOF) Append(cmds ...Value) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return os.ErrClosed
+	}
+	if err := a.syncErr; err != nil {
+		a.syncErr = nil
+		return err
+	}
+
+	// like flushAppendOnlyFile of redis a partial write is cut off, the next appends would leave it corrupt in the middle of the file
+	info, err := a.file.Stat()
+	if err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err := a.writer.Write(cmd); err != nil {
+			if truncErr := a.file.Truncate(info.Size()); truncErr != nil {
+				return errors.Join(err, truncErr)
+			}
+			return err
+		}
+	}
+	if a.opts.Fsync == FsyncAlways {
+		return a.file.Sync()
+	}
+	a.dirty = true
+	return nil
+}
+
+// Sync forces the commands appended so far to disk, whatever the policy
+func (a *AOF) Sync() error {
+	a.mu.Lock()
+	a.dirty = false
+	a.mu.Unlock()
+
+	return a.file.Sync()
+}
+
+// Close syncs the file and closes it
+func (a *AOF) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return os.ErrClosed
+	}
+	a.closed = true
+	syncErr := a.syncErr
+	a.mu.Unlock()
+
+	close(a.stop)
+	<-a.done
+
+	err := a.file.Sync()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+	return err
+}
+
+// syncs the file every second when something was appended, the appends don't wait for the sync
+func (a *AOF) syncLoop() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+
+		a.mu.Lock()
+		dirty := a.dirty
+		a.dirty = false
+		a.mu.Unlock()
+		if !dirty {
+			continue
+		}
+
+		if err := a.file.Sync(); err != nil {
+			a.mu.Lock()
+			a.syncErr = err
+			a.mu.Unlock()
+		}
+	}
+}
+
+// AOFCorruptError is returned by ReplayAOF when the file holds something that isn't a command,
+// cutting the file at Offset keeps the commands before it, like redis-check-aof --fix does
+type AOFCorruptError struct {
+	Offset int64 // where the bad record starts
+	Err    error // the ProtocolError of the reader, or ErrInvalidValue for a value that isn't a command
+}
+
+func (e *AOFCorruptError) Error() string {
+	return fmt.Sprintf("goresp: AOF corrupt at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *AOFCorruptError) Unwrap() error {
+	return e.Err
+}
+
+// AOFReplay sums up what ReplayAOF did
+type AOFReplay struct {
+	Commands  int   // the commands passed to fn
+	Truncated int64 // the bytes of an incomplete final command cut from the file, 0 when the file was whole
+}
+
+// ReplayAOF reads the commands logged in the file at path and calls fn with each one in order, a missing file replays nothing
+//
+// a final command cut short, by a crash in the middle of a write, is ignored and cut from the file
+// so it can be appended to again, like aof-load-truncated yes does in redis,
+// anything else that isn't a command stops the replay with an *AOFCorruptError and an error returned by fn is returned as is
+func ReplayAOF(path string, fn func(cmd Value) error) (AOFReplay, error) {
+	var replay AOFReplay
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return replay, nil
+	}
+	if err != nil {
+		return replay, err
+	}
+	defer file.Close()
+
+	reader := NewRespIo(file)
+	for {
+		start := reader.Offset()
+		v, err := reader.Read()
+		if err == io.EOF {
+			return replay, nil
+		}
+
+		var protoErr *ProtocolError
+		if errors.As(err, &protoErr) && errors.Is(protoErr.Err, io.ErrUnexpectedEOF) {
+			info, err := file.Stat()
+			if err != nil {
+				return replay, err
+			}
+			replay.Truncated = info.Size() - start
+			return replay, os.Truncate(path, start)
+		}
+		if protoErr != nil {
+			return replay, &AOFCorruptError{Offset: start, Err: err}
+		}
+		if err != nil {
+			return replay, err
+		}
+
+		if _, ok := commandArgs(v); !ok || len(v.Array) == 0 {
+			return replay, &AOFCorruptError{Offset: start, Err: fmt.Errorf("%w: expected a command, got %s", ErrInvalidValue, v.Typ)}
+		}
+		if err := fn(v); err != nil {
+			return replay, err
+		}
+		replay.Commands++
+	}
+}
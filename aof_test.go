@@ -0,0 +1,190 @@
+package goresp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func replayAll(t *testing.T, path string) ([]Value, AOFReplay, error) {
+	t.Helper()
+	var cmds []Value
+	replay, err := ReplayAOF(path, func(cmd Value) error {
+		cmds = append(cmds, cmd)
+		return nil
+	})
+	return cmds, replay, err
+}
+
+func TestAOF_AppendReplay(t *testing.T) {
+	cmds := []Value{
+		NewSetValue("name", "goresp"),
+		NewHsetValue("user", "age", "3"),
+		NewDelValue([]string{"a", "b"}),
+		NewCommandValue("SET", "binary", "\r\n\x00"),
+	}
+
+	for _, policy := range []FsyncPolicy{FsyncEverySec, FsyncAlways, FsyncNo} {
+		path := filepath.Join(t.TempDir(), "appendonly.aof")
+		aof, err := OpenAOFWithOptions(path, AOFOptions{Fsync: policy})
+		if err != nil {
+			t.Fatalf("OpenAOFWithOptions() returned error %v", err)
+		}
+		if err := aof.Append(cmds[0]); err != nil {
+			t.Fatalf("Append() returned error %v", err)
+		}
+		if err := aof.Append(cmds[1:]...); err != nil {
+			t.Fatalf("Append() returned error %v", err)
+		}
+		if err := aof.Close(); err != nil {
+			t.Fatalf("Close() returned error %v", err)
+		}
+		if err := aof.Append(cmds[0]); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Append() after Close returned %v, want os.ErrClosed", err)
+		}
+
+		got, replay, err := replayAll(t, path)
+		if err != nil || replay != (AOFReplay{Commands: 4}) {
+			t.Fatalf("policy %d: ReplayAOF() = %+v, %v", policy, replay, err)
+		}
+		if !reflect.DeepEqual(got, cmds) {
+			t.Errorf("policy %d: replayed %v, want %v", policy, got, cmds)
+		}
+	}
+}
+
+// writes the first n bytes it is given, then fails like a full disk
+type failAfterWriter struct {
+	w io.Writer
+	n int
+}
+
+func (f *failAfterWriter) Write(p []byte) (int, error) {
+	if len(p) <= f.n {
+		f.n -= len(p)
+		return f.w.Write(p)
+	}
+	written, _ := f.w.Write(p[:f.n])
+	f.n = 0
+	return written, errors.New("no space left on device")
+}
+
+func TestAOF_Append_WriteFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	aof, err := OpenAOFWithOptions(path, AOFOptions{Fsync: FsyncNo})
+	if err != nil {
+		t.Fatalf("OpenAOFWithOptions() returned error %v", err)
+	}
+	first, last := NewSetValue("a", "1"), NewSetValue("d", "4")
+	aof.Append(first)
+
+	// the second command is torn, the first of the same call must not stay either
+	second := NewSetValue("b", "2")
+	aof.writer = NewWriter(&failAfterWriter{w: aof.file, n: len(second.Marshal()) + 5})
+	if err := aof.Append(second, NewSetValue("c", "3")); err == nil {
+		t.Fatal("Append() returned no error for a failed write")
+	}
+	aof.writer = NewWriter(aof.file)
+	if err := aof.Append(last); err != nil {
+		t.Fatalf("Append() after a failed write returned %v", err)
+	}
+	aof.Close()
+
+	got, replay, err := replayAll(t, path)
+	if err != nil || replay != (AOFReplay{Commands: 2}) || !reflect.DeepEqual(got, []Value{first, last}) {
+		t.Errorf("ReplayAOF() = %v, %+v, %v, want the first and last commands", got, replay, err)
+	}
+}
+
+func TestReplayAOF_Truncated(t *testing.T) {
+	first := NewSetValue("a", "1").Marshal()
+	last := NewSetValue("b", "2").Marshal()
+
+	// a crash can cut the last command anywhere
+	for cut := 1; cut < len(last); cut++ {
+		path := filepath.Join(t.TempDir(), "appendonly.aof")
+		os.WriteFile(path, append(append([]byte{}, first...), last[:cut]...), 0o644)
+
+		_, replay, err := replayAll(t, path)
+		if err != nil || replay.Commands != 1 || replay.Truncated != int64(cut) {
+			t.Fatalf("cut at %d: ReplayAOF() = %+v, %v, want 1 command and %d bytes truncated", cut, replay, err, cut)
+		}
+		if info, _ := os.Stat(path); info.Size() != int64(len(first)) {
+			t.Fatalf("cut at %d: the file has %d bytes, want %d", cut, info.Size(), len(first))
+		}
+
+		// the file can be appended to again
+		aof, err := OpenAOFWithOptions(path, AOFOptions{Fsync: FsyncNo})
+		if err != nil {
+			t.Fatalf("OpenAOFWithOptions() returned error %v", err)
+		}
+		aof.Append(NewSetValue("c", "3"))
+		aof.Close()
+		if _, replay, err := replayAll(t, path); err != nil || replay != (AOFReplay{Commands: 2}) {
+			t.Errorf("cut at %d: ReplayAOF() after appending = %+v, %v", cut, replay, err)
+		}
+	}
+}
+
+func TestReplayAOF_Corrupt(t *testing.T) {
+	first := NewSetValue("a", "1").Marshal()
+	tests := []struct {
+		name  string
+		data  string
+		proto bool // the reader reports a ProtocolError
+	}{
+		{"unknown type", "?garbage\r\n", true},
+		{"bad length", "*2\r\n$x\r\n", true},
+		{"not a command", "+OK\r\n", false},
+		{"empty command", "*0\r\n", false},
+		{"integer argument", "*2\r\n$3\r\nDEL\r\n:1\r\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "appendonly.aof")
+			data := string(first) + tt.data + string(NewSetValue("b", "2").Marshal())
+			os.WriteFile(path, []byte(data), 0o644)
+
+			_, replay, err := replayAll(t, path)
+
+			var corrupt *AOFCorruptError
+			if !errors.As(err, &corrupt) || corrupt.Offset != int64(len(first)) || replay.Commands != 1 {
+				t.Fatalf("ReplayAOF() = %+v, %v, want an AOFCorruptError at offset %d", replay, err, len(first))
+			}
+			var protoErr *ProtocolError
+			if errors.As(err, &protoErr) != tt.proto {
+				t.Errorf("ReplayAOF() returned %v, ProtocolError expected: %v", err, tt.proto)
+			}
+			// a corrupt file is left as it is
+			if got, _ := os.ReadFile(path); string(got) != data {
+				t.Errorf("the file was modified to %q", got)
+			}
+		})
+	}
+}
+
+func TestReplayAOF_CallbackError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	os.WriteFile(path, append(NewSetValue("a", "1").Marshal(), NewSetValue("b", "2").Marshal()...), 0o644)
+
+	stop := errors.New("stop")
+	calls := 0
+	replay, err := ReplayAOF(path, func(cmd Value) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 || replay.Commands != 0 {
+		t.Errorf("ReplayAOF() = %+v, %v after %d calls, want the error of fn after 1", replay, err, calls)
+	}
+}
+
+func TestReplayAOF_MissingFile(t *testing.T) {
+	_, replay, err := replayAll(t, filepath.Join(t.TempDir(), "missing.aof"))
+	if err != nil || replay != (AOFReplay{}) {
+		t.Errorf("ReplayAOF() = %+v, %v, want nothing replayed", replay, err)
+	}
+}
@@ -0,0 +1,130 @@
+package goresp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// the Append functions encode a single RESP item at the end of dst and return the extended buffer,
+// they let servers write replies into a reused buffer without building Values first
+
+func AppendSimpleString(dst []byte, s string) []byte {
+	return appendLine(dst, STRING, s)
+}
+
+func AppendError(dst []byte, message string) []byte {
+	return appendLine(dst, ERROR, message)
+}
+
+func AppendInt(dst []byte, n int64) []byte {
+	dst = append(dst, INTEGER)
+	dst = strconv.AppendInt(dst, n, 10)
+	return append(dst, '\r', '\n')
+}
+
+// AppendBulk appends s as a bulk string $<length>\r\n<data>\r\n
+func AppendBulk(dst []byte, s string) []byte {
+	return appendBlob(dst, BULK, s)
+}
+
+// AppendBulkBytes is AppendBulk for a payload held in a byte slice
+func AppendBulkBytes(dst []byte, b []byte) []byte {
+	dst = appendHeader(dst, BULK, len(b))
+	dst = append(dst, b...)
+	return append(dst, '\r', '\n')
+}
+
+// AppendBulkHeader appends only the $<length>\r\n header, the caller writes the payload and its CRLF
+func AppendBulkHeader(dst []byte, length int) []byte {
+	return appendHeader(dst, BULK, length)
+}
+
+// AppendArrayHeader appends the *<length>\r\n header, it must be followed by length encoded elements
+func AppendArrayHeader(dst []byte, length int) []byte {
+	return appendHeader(dst, ARRAY, length)
+}
+
+// AppendNull appends the null bulk string $-1\r\n
+func AppendNull(dst []byte) []byte {
+	return append(dst, "$-1\r\n"...)
+}
+
+// AppendNullArray appends the null array *-1\r\n
+func AppendNullArray(dst []byte) []byte {
+	return append(dst, "*-1\r\n"...)
+}
+
+// appends <prefix><length>\r\n
+func appendHeader(dst []byte, prefix byte, length int) []byte {
+	dst = append(dst, prefix)
+	dst = strconv.AppendInt(dst, int64(length), 10)
+	return append(dst, '\r', '\n')
+}
+
+// appends <prefix><line>\r\n
+// a CR or LF in the line would end it early and desync the stream, they are replaced by spaces like redis does for error replies
+func appendLine(dst []byte, prefix byte, line string) []byte {
+	dst = append(dst, prefix)
+	start := len(dst)
+	dst = append(dst, line...)
+	if strings.ContainsAny(line, "\r\n") {
+		for i := start; i < len(dst); i++ {
+			if dst[i] == '\r' || dst[i] == '\n' {
+				dst[i] = ' '
+			}
+		}
+	}
+	return append(dst, '\r', '\n')
+}
+
+// appends a length prefixed payload <prefix><length>\r\n<data>\r\n
+func appendBlob(dst []byte, prefix byte, data string) []byte {
+	dst = appendHeader(dst, prefix, len(data))
+	dst = append(dst, data...)
+	return append(dst, '\r', '\n')
+}
+
+func appendDouble(dst []byte, f float64) []byte {
+	dst = append(dst, DOUBLE)
+	switch {
+	case math.IsInf(f, 1):
+		dst = append(dst, "inf"...)
+	case math.IsInf(f, -1):
+		dst = append(dst, "-inf"...)
+	case math.IsNaN(f):
+		dst = append(dst, "nan"...)
+	default:
+		dst = strconv.AppendFloat(dst, f, 'g', -1, 64)
+	}
+	return append(dst, '\r', '\n')
+}
+
+func appendBoolean(dst []byte, b bool) []byte {
+	if b {
+		return append(dst, "#t\r\n"...)
+	}
+	return append(dst, "#f\r\n"...)
+}
+
+// the format must be 3 bytes long, any other format is written as "txt" so the reader can find the ':'
+func appendVerbatim(dst []byte, format, text string) []byte {
+	if len(format) != 3 {
+		format = "txt"
+	}
+	dst = appendHeader(dst, VERBATIM, len(format)+1+len(text))
+	dst = append(dst, format...)
+	dst = append(dst, ':')
+	dst = append(dst, text...)
+	return append(dst, '\r', '\n')
+}
+
+// appends the key value pairs of a map or an attribute
+func appendPairs(dst []byte, prefix byte, entries []MapEntry) []byte {
+	dst = appendHeader(dst, prefix, len(entries))
+	for i := range entries {
+		dst = entries[i].Key.AppendMarshal(dst)
+		dst = entries[i].Value.AppendMarshal(dst)
+	}
+	return dst
+}
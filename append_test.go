@@ -0,0 +1,144 @@
+package goresp
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestAppendHelpers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		result   []byte
+		expected string
+	}{
+		{"SimpleString", AppendSimpleString(nil, "OK"), "+OK\r\n"},
+		{"Error", AppendError(nil, "ERR wrong"), "-ERR wrong\r\n"},
+		{"Int", AppendInt(nil, -42), ":-42\r\n"},
+		{"Bulk", AppendBulk(nil, "hello"), "$5\r\nhello\r\n"},
+		{"EmptyBulk", AppendBulk(nil, ""), "$0\r\n\r\n"},
+		{"BulkBytes", AppendBulkBytes(nil, []byte("a\x00b")), "$3\r\na\x00b\r\n"},
+		{"BulkHeader", AppendBulkHeader(nil, 1024), "$1024\r\n"},
+		{"ArrayHeader", AppendArrayHeader(nil, 3), "*3\r\n"},
+		{"Null", AppendNull(nil), "$-1\r\n"},
+		{"NullArray", AppendNullArray(nil), "*-1\r\n"},
+		{"ErrorWithNewLines", AppendError(nil, "ERR line\r\nnext\n"), "-ERR line  next \r\n"},
+		{"VerbatimBadFormat", NewVerbatimValue("markdown", "hi").Marshal(), "=6\r\ntxt:hi\r\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if string(tc.result) != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, tc.result)
+			}
+		})
+	}
+}
+
+func TestValue_AppendMarshal_KeepsPrefix(t *testing.T) {
+	dst := []byte("prefix")
+	result := NewSetValue("k", "v").AppendMarshal(dst)
+
+	expected := "prefix" + string(NewSetValue("k", "v").Marshal())
+	if string(result) != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestValue_AppendMarshal_MatchesMarshal(t *testing.T) {
+	values := []Value{
+		NewSetValue("key", "value"),
+		NewErrorValue("ERR"),
+		NewNumberValue(math.MinInt64),
+		NewNullValue(),
+		NewNullArrayValue(),
+		NewDoubleValue(2.5),
+		NewBooleanValue(true),
+		NewBigNumberValue("-123"),
+		NewVerbatimValue("txt", "hi"),
+		NewBlobErrorValue("SYNTAX"),
+		NewMapValue([]MapEntry{{Key: NewNumberValue(1), Value: NewRespSetValue([]Value{NewBooleanValue(false)})}}),
+		NewPushValue([]Value{{Typ: "bulk", Bulk: "message"}}),
+		{Typ: "array", Array: []Value{{Typ: "string", Str: "x", Attrs: []MapEntry{{Key: NewNumberValue(1), Value: NewNumberValue(2)}}}}},
+	}
+
+	var buf []byte
+	for _, v := range values {
+		buf = v.AppendMarshal(buf[:0])
+		if !bytes.Equal(buf, v.Marshal()) {
+			t.Errorf("AppendMarshal() = %q, Marshal() = %q", buf, v.Marshal())
+		}
+	}
+}
+
+func TestValue_AppendMarshal_NoAllocations(t *testing.T) {
+	v := Value{Typ: "array", Array: []Value{
+		{Typ: "bulk", Bulk: "LRANGE"},
+		{Typ: "array", Array: []Value{NewNumberValue(1), NewNumberValue(2), {Typ: "string", Str: "three"}}},
+		NewMapValue([]MapEntry{{Key: NewDoubleValue(1.5), Value: NewBooleanValue(true)}}),
+		NewNullValue(),
+	}}
+	buf := make([]byte, 0, 1024)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		buf = v.AppendMarshal(buf[:0])
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestAppendHelpers_NoAllocations(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	payload := []byte("payload")
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		buf = AppendArrayHeader(buf[:0], 3)
+		buf = AppendBulk(buf, "GET")
+		buf = AppendBulkBytes(buf, payload)
+		buf = AppendInt(buf, 1234567890)
+		buf = AppendSimpleString(buf, "OK")
+		buf = AppendError(buf, "ERR")
+		buf = AppendNull(buf)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestWriters_NoAllocations(t *testing.T) {
+	v := NewSetValue("key", "value")
+	w := NewWriter(io.Discard)
+	bw := NewBufferedWriter(io.Discard)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		w.Write(v)
+		bw.Write(v)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkValue_Marshal(b *testing.B) {
+	v := NewSetValue("user:12345", "some value of a reasonable size")
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		v.Marshal()
+	}
+}
+
+func BenchmarkValue_AppendMarshal(b *testing.B) {
+	v := NewSetValue("user:12345", "some value of a reasonable size")
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		buf = v.AppendMarshal(buf[:0])
+	}
+}
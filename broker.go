@@ -0,0 +1,404 @@
+package goresp
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// the pub/sub output limit of a Broker that doesn't set MaxOutputBuffer, the hard limit of client-output-buffer-limit pubsub in redis
+const DefaultMaxOutputBuffer = 32 * 1024 * 1024
+
+// Broker implements the pub/sub commands for a Server: SUBSCRIBE, PSUBSCRIBE, their UNSUBSCRIBE, PUBLISH and PUBSUB
+// the replies and messages have the shapes redis sends, in RESP2
+type Broker struct {
+	// MaxOutputBuffer is the most bytes a subscriber may leave unread, a slower subscriber is disconnected
+	// 0 means DefaultMaxOutputBuffer and a negative value means no limit
+	MaxOutputBuffer int
+
+	mu       sync.Mutex
+	channels map[string]map[*Conn]struct{}
+	patterns map[string]map[*Conn]struct{}
+	subs     map[*Conn]*subscriber
+}
+
+// the subscriptions of a connection
+type subscriber struct {
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func (s *subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+// NewBroker creates a broker without subscribers
+func NewBroker() *Broker {
+	return &Broker{
+		channels: map[string]map[*Conn]struct{}{},
+		patterns: map[string]map[*Conn]struct{}{},
+		subs:     map[*Conn]*subscriber{},
+	}
+}
+
+// Register adds the pub/sub commands to mux, with the arities of redis
+func (b *Broker) Register(mux *ServeMux) {
+	mux.HandleFunc("SUBSCRIBE", -2, 0, b.serveSubscribe)
+	mux.HandleFunc("PSUBSCRIBE", -2, 0, b.serveSubscribe)
+	mux.HandleFunc("UNSUBSCRIBE", -1, 0, b.serveUnsubscribe)
+	mux.HandleFunc("PUNSUBSCRIBE", -1, 0, b.serveUnsubscribe)
+	mux.HandleFunc("PUBLISH", 3, 0, func(w ReplyWriter, r *Request) {
+		w.WriteInt(int64(b.Publish(r.Args[1], r.Args[2])))
+	})
+	mux.HandleFunc("PUBSUB CHANNELS", -2, 0, b.serveChannels)
+	mux.HandleFunc("PUBSUB NUMSUB", -2, 0, b.serveNumSub)
+	mux.HandleFunc("PUBSUB NUMPAT", 2, 0, func(w ReplyWriter, r *Request) {
+		w.WriteInt(int64(b.NumPat()))
+	})
+}
+
+// Wrap puts a subscribed connection in the pub/sub context of redis: only the subscription commands, PING, QUIT and RESET
+// go through to next, PING is answered with the pong array and any other command with an error
+func (b *Broker) Wrap(next Handler) Handler {
+	return HandlerFunc(func(w ReplyWriter, r *Request) {
+		if r.Conn == nil || len(r.Args) == 0 || !b.subscribed(r.Conn) {
+			next.ServeRESP(w, r)
+			return
+		}
+
+		switch name := strings.ToLower(r.Args[0]); name {
+		case "subscribe", "psubscribe", "ssubscribe", "unsubscribe", "punsubscribe", "sunsubscribe", "quit", "reset":
+			next.ServeRESP(w, r)
+		case "ping":
+			if len(r.Args) > 2 {
+				next.ServeRESP(w, r)
+				return
+			}
+			message := ""
+			if len(r.Args) == 2 {
+				message = r.Args[1]
+			}
+			w.WriteArrayHeader(2)
+			w.WriteBulk("pong")
+			w.WriteBulk(message)
+		default:
+			w.WriteError("ERR Can't execute '" + name + "': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+		}
+	})
+}
+
+// Publish sends message to the subscribers of channel and of the patterns matching it,
+// it returns how many received it like PUBLISH, a subscriber matching several patterns counts once per pattern
+func (b *Broker) Publish(channel, message string) int {
+	limit := b.MaxOutputBuffer
+	if limit == 0 {
+		limit = DefaultMaxOutputBuffer
+	}
+	limit = max(limit, 0)
+
+	// the patterns are matched without the lock, a long pattern doesn't hold up the other commands
+	b.mu.Lock()
+	patterns := make([]string, 0, len(b.patterns))
+	for pattern := range b.patterns {
+		patterns = append(patterns, pattern)
+	}
+	b.mu.Unlock()
+	patterns = slices.DeleteFunc(patterns, func(pattern string) bool { return !globMatch(pattern, channel) })
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	receivers := 0
+	if conns := b.channels[channel]; len(conns) > 0 {
+		msg := AppendArrayHeader(nil, 3)
+		msg = AppendBulk(msg, "message")
+		msg = AppendBulk(msg, channel)
+		msg = AppendBulk(msg, message)
+		for c := range conns {
+			c.enqueue(msg, limit)
+			receivers++
+		}
+	}
+	for _, pattern := range patterns {
+		conns := b.patterns[pattern]
+		if len(conns) == 0 {
+			continue
+		}
+		msg := AppendArrayHeader(nil, 4)
+		msg = AppendBulk(msg, "pmessage")
+		msg = AppendBulk(msg, pattern)
+		msg = AppendBulk(msg, channel)
+		msg = AppendBulk(msg, message)
+		for c := range conns {
+			c.enqueue(msg, limit)
+			receivers++
+		}
+	}
+
+	return receivers
+}
+
+// Channels returns the channels with at least one subscriber that match pattern, every channel when pattern is ""
+func (b *Broker) Channels(pattern string) []string {
+	b.mu.Lock()
+	channels := make([]string, 0, len(b.channels))
+	for channel := range b.channels {
+		channels = append(channels, channel)
+	}
+	b.mu.Unlock()
+
+	if pattern != "" {
+		channels = slices.DeleteFunc(channels, func(channel string) bool { return !globMatch(pattern, channel) })
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+// NumSub returns the number of subscribers of channel, the patterns are not counted
+func (b *Broker) NumSub(channel string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.channels[channel])
+}
+
+// NumPat returns the number of patterns subscribed to, by any connection
+func (b *Broker) NumPat() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.patterns)
+}
+
+func (b *Broker) serveSubscribe(w ReplyWriter, r *Request) {
+	c := r.Conn
+	if c == nil {
+		w.WriteError("ERR " + strings.ToUpper(r.Args[0]) + " needs a connection")
+		return
+	}
+	kind := strings.ToLower(r.Args[0])
+
+	// the confirmations are queued behind the replies already written and ahead of any message,
+	// so they go through the output of the connection and not through w
+	c.flush()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := b.subs[c]
+	if sub == nil {
+		sub = &subscriber{channels: map[string]struct{}{}, patterns: map[string]struct{}{}}
+		b.subs[c] = sub
+		c.OnClose(func() { b.drop(c) })
+	}
+	own, index := sub.channels, b.channels
+	if kind == "psubscribe" {
+		own, index = sub.patterns, b.patterns
+	}
+
+	var out []byte
+	for _, name := range r.Args[1:] {
+		if _, ok := own[name]; !ok {
+			own[name] = struct{}{}
+			if index[name] == nil {
+				index[name] = map[*Conn]struct{}{}
+			}
+			index[name][c] = struct{}{}
+		}
+		out = appendConfirmation(out, kind, name, true, sub.count())
+	}
+	c.noIdleTimeout.Store(true)
+	c.enqueue(out, 0)
+}
+
+func (b *Broker) serveUnsubscribe(w ReplyWriter, r *Request) {
+	c := r.Conn
+	if c == nil {
+		w.WriteError("ERR " + strings.ToUpper(r.Args[0]) + " needs a connection")
+		return
+	}
+	kind := strings.ToLower(r.Args[0])
+
+	c.flush()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := b.subs[c]
+	if sub == nil {
+		sub = &subscriber{channels: map[string]struct{}{}, patterns: map[string]struct{}{}}
+	}
+	own, index := sub.channels, b.channels
+	if kind == "punsubscribe" {
+		own, index = sub.patterns, b.patterns
+	}
+
+	names := r.Args[1:]
+	if len(names) == 0 {
+		for name := range own {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+
+	var out []byte
+	for _, name := range names {
+		if _, ok := own[name]; ok {
+			delete(own, name)
+			unindex(index, name, c)
+		}
+		out = appendConfirmation(out, kind, name, true, sub.count())
+	}
+	// unsubscribing from everything while subscribed to nothing is confirmed with a null channel
+	if len(names) == 0 {
+		out = appendConfirmation(out, kind, "", false, sub.count())
+	}
+	if sub.count() == 0 {
+		c.noIdleTimeout.Store(false)
+	}
+	c.enqueue(out, 0)
+}
+
+func (b *Broker) serveChannels(w ReplyWriter, r *Request) {
+	pattern := ""
+	if len(r.Args) > 2 {
+		pattern = r.Args[2]
+	}
+
+	channels := b.Channels(pattern)
+	w.WriteArrayHeader(len(channels))
+	for _, channel := range channels {
+		w.WriteBulk(channel)
+	}
+}
+
+func (b *Broker) serveNumSub(w ReplyWriter, r *Request) {
+	channels := r.Args[2:]
+	w.WriteArrayHeader(2 * len(channels))
+	for _, channel := range channels {
+		w.WriteBulk(channel)
+		w.WriteInt(int64(b.NumSub(channel)))
+	}
+}
+
+// reports whether the connection is subscribed to a channel or a pattern
+func (b *Broker) subscribed(c *Conn) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := b.subs[c]
+	return sub != nil && sub.count() > 0
+}
+
+// removes the subscriptions of a closed connection
+func (b *Broker) drop(c *Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := b.subs[c]
+	if sub == nil {
+		return
+	}
+	for name := range sub.channels {
+		unindex(b.channels, name, c)
+	}
+	for name := range sub.patterns {
+		unindex(b.patterns, name, c)
+	}
+	delete(b.subs, c)
+}
+
+func unindex(index map[string]map[*Conn]struct{}, name string, c *Conn) {
+	delete(index[name], c)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+// appends the confirmation of a (p)(un)subscribe, a channel that isn't set is sent as a null bulk
+func appendConfirmation(dst []byte, kind, name string, set bool, count int) []byte {
+	dst = AppendArrayHeader(dst, 3)
+	dst = AppendBulk(dst, kind)
+	if set {
+		dst = AppendBulk(dst, name)
+	} else {
+		dst = AppendNull(dst)
+	}
+	return AppendInt(dst, int64(count))
+}
+
+// reports whether s matches the glob pattern like stringmatchlen of redis:
+// * and ? wildcards, [abc], [^abc] and [a-z] classes and \ escapes
+// a mismatch only backtracks to the last star, so the time grows with len(pattern)*len(s) and never exponentially
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, next := -1, 0 // the pattern after the last star and where in s it is being tried
+	for i < len(s) {
+		if p < len(pattern) && pattern[p] == '*' {
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			star, next = p, i
+			continue
+		}
+		if p < len(pattern) {
+			if n, ok := globToken(pattern[p:], s[i]); ok {
+				p += n
+				i++
+				continue
+			}
+		}
+		// the last star takes one more byte
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star, next
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matches c against the token that starts the pattern, it returns the length of the token
+// an unterminated class takes the rest of the pattern
+func globToken(pattern string, c byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '\\':
+		if len(pattern) >= 2 {
+			return 2, pattern[1] == c
+		}
+		return 1, c == '\\'
+	case '[':
+		i := 1
+		not := i < len(pattern) && pattern[i] == '^'
+		if not {
+			i++
+		}
+		match := false
+		for i < len(pattern) && pattern[i] != ']' {
+			switch {
+			case pattern[i] == '\\' && i+1 < len(pattern):
+				i++
+				match = match || pattern[i] == c
+			case i+2 < len(pattern) && pattern[i+1] == '-':
+				start, end := pattern[i], pattern[i+2]
+				if start > end {
+					start, end = end, start
+				}
+				i += 2
+				match = match || (c >= start && c <= end)
+			default:
+				match = match || pattern[i] == c
+			}
+			i++
+		}
+		if i < len(pattern) {
+			i++ // the closing ]
+		}
+		return i, match != not
+	default:
+		return 1, pattern[0] == c
+	}
+}
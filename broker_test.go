@@ -0,0 +1,299 @@
+package goresp
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startBrokerServer(t *testing.T, b *Broker) string {
+	t.Helper()
+	mux := newTestServerMux()
+	b.Register(mux)
+	return startServer(t, &Server{Handler: b.Wrap(mux)})
+}
+
+func dialRaw(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// sends a command and checks the exact bytes that come back
+func exchangeRaw(t *testing.T, conn net.Conn, want string, args ...string) {
+	t.Helper()
+	if len(args) > 0 {
+		conn.Write(NewCommandValue(args...).Marshal())
+	}
+	expectRaw(t, conn, want)
+}
+
+func expectRaw(t *testing.T, conn net.Conn, want string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, len(want))
+	n, err := io.ReadFull(conn, got)
+	if err != nil || string(got) != want {
+		t.Fatalf("received %q (%v), want %q", got[:n], err, want)
+	}
+}
+
+func TestBroker_Subscribe(t *testing.T) {
+	b := NewBroker()
+	addr := startBrokerServer(t, b)
+	sub := dialRaw(t, addr)
+	pub := dialRaw(t, addr)
+
+	exchangeRaw(t, sub, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$5\r\nsport\r\n:2\r\n", "SUBSCRIBE", "news", "sport")
+	exchangeRaw(t, sub, "*3\r\n$10\r\npsubscribe\r\n$5\r\nlog.*\r\n:3\r\n", "PSUBSCRIBE", "log.*")
+
+	exchangeRaw(t, pub, ":1\r\n", "PUBLISH", "news", "hello")
+	expectRaw(t, sub, "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n")
+
+	exchangeRaw(t, pub, ":1\r\n", "PUBLISH", "log.error", "disk")
+	expectRaw(t, sub, "*4\r\n$8\r\npmessage\r\n$5\r\nlog.*\r\n$9\r\nlog.error\r\n$4\r\ndisk\r\n")
+
+	exchangeRaw(t, pub, ":0\r\n", "PUBLISH", "nobody", "x")
+
+	// only the pub/sub commands are allowed while subscribed, PING gets the pong array
+	exchangeRaw(t, sub, "-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n", "GET", "k")
+	exchangeRaw(t, sub, "*2\r\n$4\r\npong\r\n$0\r\n\r\n", "PING")
+	exchangeRaw(t, sub, "*2\r\n$4\r\npong\r\n$2\r\nhi\r\n", "PING", "hi")
+
+	exchangeRaw(t, sub, "*3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:2\r\n*3\r\n$11\r\nunsubscribe\r\n$5\r\nsport\r\n:1\r\n", "UNSUBSCRIBE")
+	exchangeRaw(t, sub, "*3\r\n$12\r\npunsubscribe\r\n$5\r\nlog.*\r\n:0\r\n", "PUNSUBSCRIBE", "log.*")
+	exchangeRaw(t, sub, "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n", "UNSUBSCRIBE")
+
+	// out of the pub/sub context the connection runs any command again
+	exchangeRaw(t, sub, "+PONG\r\n", "PING")
+	exchangeRaw(t, pub, ":0\r\n", "PUBLISH", "news", "hello")
+}
+
+func TestBroker_Subscribe_PipelineOrder(t *testing.T) {
+	addr := startBrokerServer(t, NewBroker())
+	conn := dialRaw(t, addr)
+
+	// the confirmation comes after the reply of the command sent before it
+	conn.Write(append(NewCommandValue("PING").Marshal(), NewCommandValue("SUBSCRIBE", "a").Marshal()...))
+	expectRaw(t, conn, "+PONG\r\n*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+}
+
+func TestBroker_Publish_PatternCounts(t *testing.T) {
+	b := NewBroker()
+	addr := startBrokerServer(t, b)
+	sub := dialRaw(t, addr)
+	pub := dialRaw(t, addr)
+
+	exchangeRaw(t, sub, "*3\r\n$9\r\nsubscribe\r\n$5\r\nnews1\r\n:1\r\n", "SUBSCRIBE", "news1")
+	exchangeRaw(t, sub, "*3\r\n$10\r\npsubscribe\r\n$5\r\nnews*\r\n:2\r\n*3\r\n$10\r\npsubscribe\r\n$5\r\nnews?\r\n:3\r\n", "PSUBSCRIBE", "news*", "news?")
+
+	// the same connection receives the message once for the channel and once per matching pattern
+	exchangeRaw(t, pub, ":3\r\n", "PUBLISH", "news1", "x")
+}
+
+// a pattern with many stars is matched in linear steps and doesn't hold up the server
+func TestBroker_Publish_HostilePattern(t *testing.T) {
+	b := NewBroker()
+	addr := startBrokerServer(t, b)
+	sub := dialRaw(t, addr)
+	pub := dialRaw(t, addr)
+
+	pattern := strings.Repeat("*a", 12) + "*b"
+	exchangeRaw(t, sub, "*3\r\n$10\r\npsubscribe\r\n$26\r\n"+pattern+"\r\n:1\r\n", "PSUBSCRIBE", pattern)
+
+	start := time.Now()
+	exchangeRaw(t, pub, ":0\r\n", "PUBLISH", strings.Repeat("a", 60), "x")
+	exchangeRaw(t, pub, ":1\r\n", "PUBLISH", strings.Repeat("a", 60)+"b", "x")
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("PUBLISH took %v", elapsed)
+	}
+}
+
+func TestBroker_PubSubCommands(t *testing.T) {
+	b := NewBroker()
+	addr := startBrokerServer(t, b)
+	first := dialRaw(t, addr)
+	second := dialRaw(t, addr)
+
+	exchangeRaw(t, first, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$5\r\nsport\r\n:2\r\n", "SUBSCRIBE", "news", "sport")
+	exchangeRaw(t, second, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n", "SUBSCRIBE", "news")
+	exchangeRaw(t, second, "*3\r\n$10\r\npsubscribe\r\n$1\r\n*\r\n:2\r\n", "PSUBSCRIBE", "*")
+
+	client, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	reply, err := client.Do(ctx, "PUBSUB", "CHANNELS")
+	if got, _ := reply.AsArray(); err != nil || !reflect.DeepEqual(bulkStrings(got), []string{"news", "sport"}) {
+		t.Errorf("PUBSUB CHANNELS = %s, %v", FormatReply(reply), err)
+	}
+	reply, err = client.Do(ctx, "PUBSUB", "CHANNELS", "s*")
+	if got, _ := reply.AsArray(); err != nil || !reflect.DeepEqual(bulkStrings(got), []string{"sport"}) {
+		t.Errorf("PUBSUB CHANNELS s* = %s, %v", FormatReply(reply), err)
+	}
+	reply, err = client.Do(ctx, "PUBSUB", "NUMSUB", "news", "sport", "none")
+	if err != nil || FormatReply(reply) != "1) \"news\"\n2) (integer) 2\n3) \"sport\"\n4) (integer) 1\n5) \"none\"\n6) (integer) 0\n" {
+		t.Errorf("PUBSUB NUMSUB = %s, %v", FormatReply(reply), err)
+	}
+	if reply, err = client.Do(ctx, "PUBSUB", "NUMPAT"); err != nil || reply.Num != 1 {
+		t.Errorf("PUBSUB NUMPAT = %s, %v", FormatReply(reply), err)
+	}
+
+	// the subscriptions of a connection go away with it
+	first.Close()
+	for deadline := time.Now().Add(time.Second); b.NumSub("news") != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("NumSub(news) = %d after a subscriber left, want 1", b.NumSub("news"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := b.Channels(""); !reflect.DeepEqual(got, []string{"news"}) {
+		t.Errorf("Channels() = %v after a subscriber left", got)
+	}
+}
+
+func bulkStrings(values []Value) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i], _ = v.AsString()
+	}
+	return out
+}
+
+func TestBroker_SlowConsumer(t *testing.T) {
+	b := NewBroker()
+	b.MaxOutputBuffer = 64 * 1024
+	addr := startBrokerServer(t, b)
+
+	slow := dialRaw(t, addr)
+	exchangeRaw(t, slow, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n", "SUBSCRIBE", "news")
+
+	// the subscriber never reads, once the socket buffers are full the output grows past the limit
+	payload := strings.Repeat("x", 16*1024)
+	for i := 0; i < 10000 && b.NumSub("news") > 0; i++ {
+		b.Publish("news", payload)
+	}
+	for deadline := time.Now().Add(time.Second); b.NumSub("news") > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("the slow subscriber was never disconnected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	slow.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.Copy(io.Discard, slow); err != nil {
+		t.Errorf("the connection of the slow subscriber should be closed, reading it returned %v", err)
+	}
+}
+
+func TestBroker_WithPubSubClient(t *testing.T) {
+	addr := startBrokerServer(t, NewBroker())
+	ctx := context.Background()
+
+	ps, err := NewPubSub(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatalf("NewPubSub() returned error %v", err)
+	}
+	defer ps.Close()
+	if err := ps.Subscribe(ctx, "news"); err != nil {
+		t.Fatalf("Subscribe() returned error %v", err)
+	}
+	if err := ps.PSubscribe(ctx, "n*"); err != nil {
+		t.Fatalf("PSubscribe() returned error %v", err)
+	}
+	if err := ps.Ping(ctx); err != nil {
+		t.Fatalf("Ping() returned error %v", err)
+	}
+
+	client, err := Dial(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer client.Close()
+	if reply, err := client.Do(ctx, "PUBLISH", "news", "hi"); err != nil || reply.Num != 2 {
+		t.Fatalf("PUBLISH = %+v, %v, want 2 receivers", reply, err)
+	}
+
+	want := []Message{
+		{Kind: "message", Channel: "news", Payload: "hi"},
+		{Kind: "pmessage", Pattern: "n*", Channel: "news", Payload: "hi"},
+	}
+	for _, w := range want {
+		if got := receive(t, ps); got != w {
+			t.Errorf("received %+v, want %+v", got, w)
+		}
+	}
+}
+
+func TestBroker_IdleTimeoutSkipsSubscribers(t *testing.T) {
+	b := NewBroker()
+	mux := NewServeMux()
+	b.Register(mux)
+	addr := startServer(t, &Server{Handler: b.Wrap(mux), IdleTimeout: 20 * time.Millisecond})
+
+	conn := dialRaw(t, addr)
+	exchangeRaw(t, conn, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n", "SUBSCRIBE", "news")
+	time.Sleep(60 * time.Millisecond)
+
+	if n := b.Publish("news", "still here"); n != 1 {
+		t.Fatalf("Publish() reached %d subscribers, the idle subscriber should be kept", n)
+	}
+	expectRaw(t, conn, "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$10\r\nstill here\r\n")
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"news.*", "news.sport", true},
+		{"news.*", "news", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`[\]]`, "]", true},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"**x", "abcx", true},
+		{"[abc", "a", true},
+		{"[abc", "ab", false},
+		{"news", "news", true},
+		{"news", "new", false},
+		{"", "", true},
+		{"", "a", false},
+		{"*a*b", "xaybzb", true},
+		{"a*", "a", true},
+		{"*?", "", false},
+		{"*[0-9]", "abc1", true},
+		{`*\`, `a\`, true},
+		{strings.Repeat("*a", 12) + "*b", strings.Repeat("a", 60), false},
+		{strings.Repeat("*a", 12) + "*b", strings.Repeat("a", 60) + "b", true},
+	}
+
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
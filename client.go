@@ -0,0 +1,257 @@
+package goresp
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by a Client that was closed, either by Close or after an I/O or protocol error left its connection unusable
+var ErrClosed = errors.New("goresp: client closed")
+
+// ClientOptions configure a Client
+type ClientOptions struct {
+	Reader ReaderOptions // the limits applied to the replies, DefaultReaderOptions when it is the zero value
+}
+
+// Client sends commands to a RESP server over a single connection and reads their replies,
+// it is safe for concurrent use but the callers take turns on the connection
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *RespIo
+	buf    []byte // the encoded command, reused by every Do
+	err    error  // why the client can't be used anymore, nil while it is healthy
+
+	created, used time.Time // kept by Pool for MaxLifetime and IdleTimeout
+}
+
+// Dial connects to a server, network is "tcp" or "unix" like for net.Dial, ctx bounds the time spent connecting
+func Dial(ctx context.Context, network, address string) (*Client, error) {
+	return DialWithOptions(ctx, network, address, ClientOptions{})
+}
+
+func DialWithOptions(ctx context.Context, network, address string, opts ClientOptions) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClientWithOptions(conn, opts), nil
+}
+
+// NewClient creates a Client on top of an established connection, the client owns it and closes it in Close
+func NewClient(conn net.Conn) *Client {
+	return NewClientWithOptions(conn, ClientOptions{})
+}
+
+func NewClientWithOptions(conn net.Conn, opts ClientOptions) *Client {
+	readerOpts := opts.Reader
+	if readerOpts == (ReaderOptions{}) {
+		readerOpts = DefaultReaderOptions()
+	}
+
+	return &Client{conn: conn, reader: NewRespIoWithOptions(conn, readerOpts)}
+}
+
+// Do sends a command and returns its reply, the arguments are sent as bulk strings:
+// strings and []byte as is, numbers and bools as their decimal text (bools as 1 and 0) and encoding.TextMarshaler as its text
+//
+// an error reply is returned along with a *ReplyError, any other error means the connection failed and the client is closed
+// the deadline of ctx is applied to the connection and cancelling ctx interrupts a pending read or write
+func (c *Client) Do(ctx context.Context, args ...any) (Value, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return Value{}, c.err
+	}
+
+	buf, err := appendCommand(c.buf[:0], args)
+	if err != nil {
+		return Value{}, err
+	}
+	c.buf = buf
+
+	v, err := c.roundTrip(ctx, buf, 1)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return v[0], v[0].Err()
+}
+
+// Close closes the connection, a Do that is waiting for its reply fails with ErrClosed
+func (c *Client) Close() error {
+	// the connection is closed before taking the lock so a Do blocked on it returns
+	err := c.conn.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == ErrClosed {
+		return nil
+	}
+	c.err = ErrClosed
+
+	return err
+}
+
+// reports whether the connection failed or was closed
+func (c *Client) broken() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
+
+// writes the encoded commands and reads n replies, it must be called with the lock held
+// a failure leaves the stream in an unknown state so the connection is closed, the replies read before it are returned with the error
+func (c *Client) roundTrip(ctx context.Context, cmds []byte, n int) ([]Value, error) {
+	stop, err := c.watch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	replies, err := c.exchange(cmds, n)
+	if err != nil {
+		// the deadlines of the connection all come from ctx, so a timeout is reported like the context package does
+		// even when the connection noticed it a moment before ctx did
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		} else if errors.Is(err, os.ErrDeadlineExceeded) {
+			err = context.DeadlineExceeded
+		} else if errors.Is(err, net.ErrClosed) {
+			err = ErrClosed
+		}
+		c.conn.Close()
+		c.err = ErrClosed
+		return replies, err
+	}
+
+	return replies, nil
+}
+
+func (c *Client) exchange(cmds []byte, n int) ([]Value, error) {
+	if _, err := c.conn.Write(cmds); err != nil {
+		return nil, err
+	}
+
+	replies := make([]Value, n)
+	for i := range replies {
+		v, err := c.reader.Read()
+		if err != nil {
+			return replies[:i], err
+		}
+		replies[i] = v
+	}
+
+	return replies, nil
+}
+
+// a deadline in the past that makes the pending I/O of the connection fail at once
+var aLongTimeAgo = time.Unix(1, 0)
+
+// applies the deadline of ctx to the connection and interrupts it when ctx is cancelled, stop undoes both
+func (c *Client) watch(ctx context.Context) (stop func(), err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if ctx.Done() == nil {
+		return func() {}, nil
+	}
+
+	cancelled := make(chan struct{})
+	stopCancel := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(aLongTimeAgo)
+		close(cancelled)
+	})
+	return func() {
+		if !stopCancel() {
+			// ctx was cancelled, wait for the past deadline to be set so it can't land on the next command
+			<-cancelled
+			c.conn.SetDeadline(time.Time{})
+		} else if !deadline.IsZero() {
+			c.conn.SetDeadline(time.Time{})
+		}
+	}, nil
+}
+
+// appends args as an array of bulk strings, the command a client sends
+func appendCommand(dst []byte, args []any) ([]byte, error) {
+	dst = AppendArrayHeader(dst, len(args))
+	for _, arg := range args {
+		var err error
+		if dst, err = appendArg(dst, arg); err != nil {
+			return dst, err
+		}
+	}
+
+	return dst, nil
+}
+
+// appends a single argument of Do as a bulk string
+func appendArg(dst []byte, arg any) ([]byte, error) {
+	var scratch [64]byte
+
+	switch a := arg.(type) {
+	case string:
+		return AppendBulk(dst, a), nil
+	case []byte:
+		return AppendBulkBytes(dst, a), nil
+	case int:
+		return AppendBulkBytes(dst, strconv.AppendInt(scratch[:0], int64(a), 10)), nil
+	case int64:
+		return AppendBulkBytes(dst, strconv.AppendInt(scratch[:0], a, 10)), nil
+	case float64:
+		return AppendBulkBytes(dst, strconv.AppendFloat(scratch[:0], a, 'f', -1, 64)), nil
+	case bool:
+		if a {
+			return AppendBulk(dst, "1"), nil
+		}
+		return AppendBulk(dst, "0"), nil
+	case encoding.TextMarshaler:
+		text, err := a.MarshalText()
+		if err != nil {
+			return dst, err
+		}
+		return AppendBulkBytes(dst, text), nil
+	}
+
+	// the other sizes of numbers and the named types like type Key string
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() {
+	case reflect.String:
+		return AppendBulk(dst, rv.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendBulkBytes(dst, strconv.AppendInt(scratch[:0], rv.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return AppendBulkBytes(dst, strconv.AppendUint(scratch[:0], rv.Uint(), 10)), nil
+	case reflect.Float32:
+		return AppendBulkBytes(dst, strconv.AppendFloat(scratch[:0], rv.Float(), 'f', -1, 32)), nil
+	case reflect.Float64:
+		return AppendBulkBytes(dst, strconv.AppendFloat(scratch[:0], rv.Float(), 'f', -1, 64)), nil
+	case reflect.Bool:
+		return appendArg(dst, rv.Bool())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return AppendBulkBytes(dst, rv.Bytes()), nil
+		}
+	}
+
+	if arg == nil {
+		return dst, &UnsupportedTypeError{Type: reflect.TypeOf((*any)(nil)).Elem()}
+	}
+	return dst, &UnsupportedTypeError{Type: rv.Type()}
+}
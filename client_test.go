@@ -0,0 +1,338 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is an in-process server that answers every command with the reply of handle,
+// a zero Value marshals to nothing so returning it leaves the command without a reply
+type fakeServer struct {
+	listener net.Listener
+	handle   func(args []string) Value
+
+	mu    sync.Mutex
+	conns []net.Conn
+	wg    sync.WaitGroup
+}
+
+func newFakeServer(t *testing.T, network string, handle func(args []string) Value) *fakeServer {
+	t.Helper()
+
+	address := "127.0.0.1:0"
+	if network == "unix" {
+		address = filepath.Join(t.TempDir(), "fake.sock")
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("Listen() returned error %v", err)
+	}
+
+	s := &fakeServer{listener: listener, handle: handle}
+	s.wg.Add(1)
+	go s.accept()
+	t.Cleanup(s.close)
+
+	return s
+}
+
+func (s *fakeServer) addr() (network, address string) {
+	return s.listener.Addr().Network(), s.listener.Addr().String()
+}
+
+func (s *fakeServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer s.wg.Done()
+	reader := NewRespIo(conn)
+	for {
+		cmd, err := reader.Read()
+		if err != nil {
+			return
+		}
+		args := make([]string, len(cmd.Array))
+		for i, arg := range cmd.Array {
+			args[i] = arg.Bulk
+		}
+		if _, err := conn.Write(s.handle(args).Marshal()); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// a tiny key value store that understands PING, SET, GET and INCR
+func newFakeStore() func(args []string) Value {
+	var mu sync.Mutex
+	data := map[string]string{}
+
+	return func(args []string) Value {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case len(args) == 1 && args[0] == "PING":
+			return Value{Typ: KindString, Str: "PONG"}
+		case len(args) == 3 && args[0] == "SET":
+			data[args[1]] = args[2]
+			return Value{Typ: KindString, Str: "OK"}
+		case len(args) == 2 && args[0] == "GET":
+			v, ok := data[args[1]]
+			if !ok {
+				return NewNullValue()
+			}
+			return Value{Typ: KindBulk, Bulk: v}
+		case len(args) == 2 && args[0] == "INCR":
+			n, err := strconv.ParseInt(data[args[1]], 10, 64)
+			if err != nil && data[args[1]] != "" {
+				return NewErrorValue("ERR value is not an integer or out of range")
+			}
+			data[args[1]] = strconv.FormatInt(n+1, 10)
+			return NewNumberValue(n + 1)
+		case len(args) > 0 && args[0] == "ECHO":
+			return NewCommandValue(args[1:]...)
+		default:
+			return NewErrorValue("ERR unknown command")
+		}
+	}
+}
+
+func dialFake(t *testing.T, s *fakeServer) *Client {
+	t.Helper()
+	network, address := s.addr()
+	client, err := Dial(context.Background(), network, address)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestClient_Do(t *testing.T) {
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			client := dialFake(t, newFakeServer(t, network, newFakeStore()))
+			ctx := context.Background()
+
+			reply, err := client.Do(ctx, "SET", "key", "value")
+			if err != nil || reply.Str != "OK" {
+				t.Fatalf("Do(SET) = %+v, %v, want OK", reply, err)
+			}
+
+			reply, err = client.Do(ctx, "GET", "key")
+			if err != nil || reply.Bulk != "value" {
+				t.Fatalf("Do(GET) = %+v, %v, want value", reply, err)
+			}
+
+			reply, err = client.Do(ctx, "GET", "missing")
+			if err != nil || !reply.IsNull() {
+				t.Fatalf("Do(GET missing) = %+v, %v, want null", reply, err)
+			}
+		})
+	}
+}
+
+type testKey string
+
+type textArg struct{}
+
+func (textArg) MarshalText() ([]byte, error) {
+	return []byte("text"), nil
+}
+
+func TestClient_Do_ArgumentTypes(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+
+	reply, err := client.Do(context.Background(), "ECHO", "s", []byte("b"), 42, int8(-8), uint64(7), 1.5, float32(0.25), true, false, testKey("k"), textArg{})
+	if err != nil {
+		t.Fatalf("Do() returned error %v", err)
+	}
+
+	expected := []string{"s", "b", "42", "-8", "7", "1.5", "0.25", "1", "0", "k", "text"}
+	items, _ := reply.AsArray()
+	if len(items) != len(expected) {
+		t.Fatalf("Do() = %+v, want %v", reply, expected)
+	}
+	for i, item := range items {
+		if item.Bulk != expected[i] {
+			t.Errorf("argument %d was sent as %q, want %q", i, item.Bulk, expected[i])
+		}
+	}
+}
+
+func TestClient_Do_UnsupportedArgument(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+
+	var typeErr *UnsupportedTypeError
+	if _, err := client.Do(context.Background(), "SET", "k", map[string]int{}); !errors.As(err, &typeErr) {
+		t.Fatalf("Do() with a map argument returned %v, want an UnsupportedTypeError", err)
+	}
+	if _, err := client.Do(context.Background(), "SET", "k", nil); !errors.As(err, &typeErr) {
+		t.Fatalf("Do() with a nil argument returned %v, want an UnsupportedTypeError", err)
+	}
+
+	// nothing was sent so the client is still in sync
+	if reply, err := client.Do(context.Background(), "PING"); err != nil || reply.Str != "PONG" {
+		t.Errorf("Do(PING) after a bad argument = %+v, %v, want PONG", reply, err)
+	}
+}
+
+func TestClient_Do_ErrorReply(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+	ctx := context.Background()
+
+	reply, err := client.Do(ctx, "NOPE")
+
+	var replyErr *ReplyError
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("Do() returned %v, want a *ReplyError", err)
+	}
+	if replyErr.Prefix() != "ERR" || replyErr.Error() != "ERR unknown command" {
+		t.Errorf("ReplyError = %q with prefix %q", replyErr.Error(), replyErr.Prefix())
+	}
+	if !reply.IsError() {
+		t.Errorf("Do() should return the error reply along with the error, got %+v", reply)
+	}
+
+	// an error reply is a normal reply, the connection is still usable
+	if reply, err := client.Do(ctx, "PING"); err != nil || reply.Str != "PONG" {
+		t.Errorf("Do(PING) after an error reply = %+v, %v, want PONG", reply, err)
+	}
+}
+
+func TestClient_Do_ContextDeadline(t *testing.T) {
+	// the server never answers
+	client := dialFake(t, newFakeServer(t, "tcp", func([]string) Value { return Value{} }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Do(ctx, "PING")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Do() returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do() returned after %v, the deadline was 50ms", elapsed)
+	}
+
+	// the reply may still arrive so the connection can't be reused
+	if _, err := client.Do(context.Background(), "PING"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Do() after a timeout returned %v, want ErrClosed", err)
+	}
+}
+
+func TestClient_Do_ContextCancel(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", func([]string) Value { return Value{} }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if _, err := client.Do(ctx, "PING"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do() returned %v, want context.Canceled", err)
+	}
+}
+
+func TestClient_Do_CancelledContextDoesNotBreakTheNextCommand(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := client.Do(ctx, "PING"); err != nil {
+		t.Fatalf("Do() returned error %v", err)
+	}
+	cancel()
+
+	if _, err := client.Do(ctx, "PING"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do() with a cancelled context returned %v, want context.Canceled", err)
+	}
+	if reply, err := client.Do(context.Background(), "PING"); err != nil || reply.Str != "PONG" {
+		t.Errorf("Do(PING) = %+v, %v, want PONG", reply, err)
+	}
+}
+
+func TestClient_Close(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", func([]string) Value { return Value{} }))
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Do(context.Background(), "PING")
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() returned error %v", err)
+	}
+	if err := <-done; !errors.Is(err, ErrClosed) {
+		t.Errorf("pending Do() returned %v, want ErrClosed", err)
+	}
+	if _, err := client.Do(context.Background(), "PING"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Do() after Close returned %v, want ErrClosed", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("second Close() returned error %v", err)
+	}
+}
+
+func TestClient_Do_Concurrent(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := client.Do(context.Background(), "INCR", "counter"); err != nil {
+					t.Errorf("Do(INCR) returned error %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := client.Do(context.Background(), "GET", "counter")
+	if err != nil || n.Bulk != "400" {
+		t.Errorf("counter = %+v, %v, want 400", n, err)
+	}
+}
+
+func TestClient_Dial_Error(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	listener.Close()
+
+	if _, err := Dial(context.Background(), "tcp", address); err == nil {
+		t.Error("Dial() to a closed port should fail")
+	}
+}
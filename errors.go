@@ -0,0 +1,80 @@
+package goresp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// the sentinel errors wrapped by ProtocolError, use errors.Is to check for them
+var (
+	ErrUnknownType   = errors.New("unknown type")
+	ErrInvalidLength = errors.New("invalid length")
+	ErrMissingCRLF   = errors.New("missing CRLF")
+	ErrInvalidValue  = errors.New("invalid value")
+
+	// ErrLimitExceeded is wrapped by all the errors of the ReaderOptions limits
+	ErrLimitExceeded = errors.New("limit exceeded")
+	ErrBulkTooLarge  = fmt.Errorf("%w: bulk too large", ErrLimitExceeded)
+	ErrArrayTooLong  = fmt.Errorf("%w: aggregate too long", ErrLimitExceeded)
+	ErrTooDeep       = fmt.Errorf("%w: nesting too deep", ErrLimitExceeded)
+	ErrLineTooLong   = fmt.Errorf("%w: line too long", ErrLimitExceeded)
+	ErrValueTooLarge = fmt.Errorf("%w: value too large", ErrLimitExceeded)
+)
+
+// the errors of the Value accessors like AsInt and AsString
+var (
+	ErrNil       = errors.New("goresp: nil reply")
+	ErrWrongKind = errors.New("goresp: wrong kind")
+)
+
+// the offending bytes are cut to this size in the error message
+const maxErrorGot = 32
+
+// ProtocolError is returned by RespIo when the stream does not follow the protocol
+// truncated values are reported with Err set to io.ErrUnexpectedEOF
+type ProtocolError struct {
+	Offset   int64  // the offset in bytes from the start of the stream where the problem was found
+	Prefix   byte   // the type prefix of the value that was being read, 0 if unknown
+	Expected string // what the reader expected to find
+	Got      string // what the reader found instead
+	Err      error  // one of the sentinel errors or io.ErrUnexpectedEOF
+}
+
+func (e *ProtocolError) Error() string {
+	msg := fmt.Sprintf("goresp: %v at offset %d", e.Err, e.Offset)
+	if e.Prefix != 0 {
+		msg += fmt.Sprintf(" reading '%c'", e.Prefix)
+	}
+	if e.Expected != "" {
+		msg += ": expected " + e.Expected
+	}
+	if e.Got != "" {
+		got := e.Got
+		if len(got) > maxErrorGot {
+			got = got[:maxErrorGot] + "..."
+		}
+		msg += fmt.Sprintf(", got %q", got)
+	}
+
+	return msg
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// ReplyError is an error reply sent by the peer like "ERR unknown command" or "WRONGTYPE ..."
+type ReplyError struct {
+	Message string
+}
+
+func (e *ReplyError) Error() string {
+	return e.Message
+}
+
+// Prefix returns the first word of the message, the error code like "ERR" or "WRONGTYPE"
+func (e *ReplyError) Prefix() string {
+	prefix, _, _ := strings.Cut(e.Message, " ")
+	return prefix
+}
@@ -0,0 +1,37 @@
+package goresp
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProtocolError_Error(t *testing.T) {
+	err := &ProtocolError{Offset: 12, Prefix: BULK, Expected: "a non negative length", Got: "-5", Err: ErrInvalidLength}
+
+	expected := `goresp: invalid length at offset 12 reading '$': expected a non negative length, got "-5"`
+	if err.Error() != expected {
+		t.Errorf("Error() = %q, want %q", err.Error(), expected)
+	}
+}
+
+func TestProtocolError_ErrorTruncatesGot(t *testing.T) {
+	err := &ProtocolError{Got: strings.Repeat("a", 100), Err: ErrInvalidValue}
+
+	if !strings.Contains(err.Error(), strings.Repeat("a", maxErrorGot)+"...") {
+		t.Errorf("Expected the got part to be cut to %d bytes, got %q", maxErrorGot, err.Error())
+	}
+}
+
+func TestProtocolError_Unwrap(t *testing.T) {
+	var err error = &ProtocolError{Err: io.ErrUnexpectedEOF}
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected errors.Is to find io.ErrUnexpectedEOF")
+	}
+
+	if errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected errors.Is not to match a different sentinel")
+	}
+}
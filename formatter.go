@@ -0,0 +1,198 @@
+package goresp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatReply renders a reply exactly like redis-cli does on a terminal, with (integer), (nil) and (error) tags,
+// quoted strings and numbered elements like 1) "first" where nested aggregates are indented under their index
+// the result always ends with a new line
+func FormatReply(v Value) string {
+	var sb strings.Builder
+	formatTTY(&sb, v, "")
+	return sb.String()
+}
+
+// FormatReplyRaw renders a reply like redis-cli --raw does, strings are printed as is
+// and the elements of aggregates are separated by new lines
+func FormatReplyRaw(v Value) string {
+	var sb strings.Builder
+	formatRaw(&sb, v)
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// it follows cliFormatReplyTTY of redis-cli, prefix is the indentation of the nested aggregates
+func formatTTY(sb *strings.Builder, v Value, prefix string) {
+	switch v.Typ {
+	case KindError, KindBlobError:
+		sb.WriteString("(error) ")
+		sb.WriteString(v.Str)
+		sb.WriteByte('\n')
+	case KindString:
+		sb.WriteString(v.Str)
+		sb.WriteByte('\n')
+	case KindInt, KindInteger:
+		fmt.Fprintf(sb, "(integer) %d\n", v.Num)
+	case KindDouble:
+		sb.WriteString("(double) ")
+		sb.WriteString(formatDouble(v))
+		sb.WriteByte('\n')
+	case KindBigNumber:
+		sb.WriteString("(big number) ")
+		sb.WriteString(v.Str)
+		sb.WriteByte('\n')
+	case KindBulk:
+		sb.WriteString(Quote(v.Bulk))
+		sb.WriteByte('\n')
+	case KindVerbatim:
+		sb.WriteString(v.Bulk)
+		sb.WriteByte('\n')
+	case KindNull, KindNullArray:
+		sb.WriteString("(nil)\n")
+	case KindBoolean:
+		if v.Bool {
+			sb.WriteString("(true)\n")
+		} else {
+			sb.WriteString("(false)\n")
+		}
+	case KindArray, KindSet, KindPush:
+		formatAggregateTTY(sb, v.Typ, v.Array, nil, prefix)
+	case KindMap:
+		formatAggregateTTY(sb, v.Typ, nil, v.Map, prefix)
+	default:
+		fmt.Fprintf(sb, "(unknown reply type %s)\n", v.Typ)
+	}
+}
+
+func formatAggregateTTY(sb *strings.Builder, typ Kind, items []Value, entries []MapEntry, prefix string) {
+	count := len(items)
+	if typ == KindMap {
+		count = len(entries)
+	}
+
+	if count == 0 {
+		switch typ {
+		case KindMap:
+			sb.WriteString("(empty hash)\n")
+		case KindSet:
+			sb.WriteString("(empty set)\n")
+		case KindPush:
+			sb.WriteString("(empty push)\n")
+		default:
+			sb.WriteString("(empty array)\n")
+		}
+		return
+	}
+
+	// the indexes are right aligned and the nested replies are indented past them
+	idxlen := len(strconv.Itoa(count))
+	nested := prefix + strings.Repeat(" ", idxlen+2)
+
+	sep := ')'
+	switch typ {
+	case KindSet:
+		sep = '~'
+	case KindMap:
+		sep = '#'
+	}
+
+	for i := 0; i < count; i++ {
+		// the first index is printed right after the index of the parent so it doesn't get the prefix
+		if i > 0 {
+			sb.WriteString(prefix)
+		}
+		fmt.Fprintf(sb, "%*d%c ", idxlen, i+1, sep)
+
+		if typ != KindMap {
+			formatTTY(sb, items[i], nested)
+			continue
+		}
+
+		var key strings.Builder
+		formatTTY(&key, entries[i].Key, nested)
+		sb.WriteString(strings.TrimSuffix(key.String(), "\n"))
+		sb.WriteString(" => ")
+		formatTTY(sb, entries[i].Value, nested)
+	}
+}
+
+// it follows cliFormatReplyRaw of redis-cli
+func formatRaw(sb *strings.Builder, v Value) {
+	switch v.Typ {
+	case KindError, KindBlobError, KindString, KindBigNumber:
+		sb.WriteString(v.Str)
+	case KindBulk, KindVerbatim:
+		sb.WriteString(v.Bulk)
+	case KindInt, KindInteger:
+		sb.WriteString(strconv.FormatInt(v.Num, 10))
+	case KindDouble:
+		sb.WriteString(formatDouble(v))
+	case KindBoolean:
+		if v.Bool {
+			sb.WriteString("(true)")
+		} else {
+			sb.WriteString("(false)")
+		}
+	case KindArray, KindSet, KindPush:
+		for i, item := range v.Array {
+			if i > 0 {
+				sb.WriteByte('\n')
+			}
+			formatRaw(sb, item)
+		}
+	case KindMap:
+		for i, entry := range v.Map {
+			if i > 0 {
+				sb.WriteByte('\n')
+			}
+			formatRaw(sb, entry.Key)
+			sb.WriteByte('\n')
+			formatRaw(sb, entry.Value)
+		}
+	}
+}
+
+// the text of a double as it is sent on the wire, redis-cli prints the text it received
+func formatDouble(v Value) string {
+	if v.Str != "" && textIsDouble(v.Str, v.Double) {
+		return v.Str
+	}
+	b := appendDouble(nil, v.Double)
+	return string(b[1 : len(b)-2])
+}
+
+// Quote returns s in double quotes with the escapes of redis sdscatrepr,
+// the same escapes SplitArgs understands so the output can be pasted back in redis-cli
+func Quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		default:
+			if c >= 0x20 && c < 0x7f {
+				sb.WriteByte(c)
+			} else {
+				fmt.Fprintf(&sb, `\x%02x`, c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
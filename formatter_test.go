@@ -0,0 +1,119 @@
+package goresp
+
+import (
+	"math"
+	"testing"
+)
+
+func bulk(s string) Value {
+	return Value{Typ: "bulk", Bulk: s}
+}
+
+func TestFormatReply(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    Value
+		expected string
+	}{
+		{"Status", Value{Typ: "string", Str: "OK"}, "OK\n"},
+		{"Error", NewErrorValue("ERR unknown command"), "(error) ERR unknown command\n"},
+		{"BlobError", NewBlobErrorValue("SYNTAX invalid"), "(error) SYNTAX invalid\n"},
+		{"Integer", NewNumberValue(5), "(integer) 5\n"},
+		{"LegacyInteger", Value{Typ: KindInteger, Num: -3}, "(integer) -3\n"},
+		{"Bulk", bulk("hello"), "\"hello\"\n"},
+		{"BulkEscapes", bulk("a\"b\\c\n\x00é"), "\"a\\\"b\\\\c\\n\\x00\\xc3\\xa9\"\n"},
+		{"Null", NewNullValue(), "(nil)\n"},
+		{"NullArray", NewNullArrayValue(), "(nil)\n"},
+		{"EmptyArray", Value{Typ: "array", Array: []Value{}}, "(empty array)\n"},
+		{"EmptySet", NewRespSetValue(nil), "(empty set)\n"},
+		{"EmptyMap", NewMapValue(nil), "(empty hash)\n"},
+		{"Double", NewDoubleValue(3.14), "(double) 3.14\n"},
+		{"DoubleInf", NewDoubleValue(math.Inf(-1)), "(double) -inf\n"},
+		{"Boolean", NewBooleanValue(true), "(true)\n"},
+		{"BigNumber", NewBigNumberValue("1234567890123456789012"), "(big number) 1234567890123456789012\n"},
+		{"Verbatim", NewVerbatimValue("txt", "line 1\nline 2"), "line 1\nline 2\n"},
+		{
+			"Array",
+			Value{Typ: "array", Array: []Value{bulk("a"), bulk("b"), NewNumberValue(3)}},
+			"1) \"a\"\n2) \"b\"\n3) (integer) 3\n",
+		},
+		{
+			"NestedArray",
+			Value{Typ: "array", Array: []Value{
+				bulk("a"),
+				{Typ: "array", Array: []Value{NewNullValue(), NewErrorValue("ERR x")}},
+				{Typ: "array", Array: []Value{}},
+			}},
+			"1) \"a\"\n2) 1) (nil)\n   2) (error) ERR x\n3) (empty array)\n",
+		},
+		{
+			"TenElementsAreAligned",
+			NewCommandValue("a", "b", "c", "d", "e", "f", "g", "h", "i", "j"),
+			" 1) \"a\"\n 2) \"b\"\n 3) \"c\"\n 4) \"d\"\n 5) \"e\"\n 6) \"f\"\n 7) \"g\"\n 8) \"h\"\n 9) \"i\"\n10) \"j\"\n",
+		},
+		{
+			"NestedUnderWideIndex",
+			Value{Typ: "array", Array: append(NewCommandValue("1", "2", "3", "4", "5", "6", "7", "8", "9").Array, NewCommandValue("x", "y"))},
+			" 1) \"1\"\n 2) \"2\"\n 3) \"3\"\n 4) \"4\"\n 5) \"5\"\n 6) \"6\"\n 7) \"7\"\n 8) \"8\"\n 9) \"9\"\n10) 1) \"x\"\n    2) \"y\"\n",
+		},
+		{
+			"Set",
+			NewRespSetValue([]Value{bulk("x"), bulk("y")}),
+			"1~ \"x\"\n2~ \"y\"\n",
+		},
+		{
+			"Map",
+			NewMapValue([]MapEntry{
+				{Key: bulk("name"), Value: bulk("ann")},
+				{Key: bulk("tags"), Value: NewCommandValue("a", "b")},
+			}),
+			// like redis-cli the values of a map are indented by the index width only, not by the key
+			"1# \"name\" => \"ann\"\n2# \"tags\" => 1) \"a\"\n   2) \"b\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := FormatReply(tc.value)
+			if result != tc.expected {
+				t.Errorf("FormatReply() = %q, want %q", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFormatReplyRaw(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    Value
+		expected string
+	}{
+		{"Status", Value{Typ: "string", Str: "OK"}, "OK\n"},
+		{"Error", NewErrorValue("ERR x"), "ERR x\n"},
+		{"Integer", NewNumberValue(5), "5\n"},
+		{"Bulk", bulk("a\nb"), "a\nb\n"},
+		{"Null", NewNullValue(), "\n"},
+		{"Array", NewCommandValue("a", "b"), "a\nb\n"},
+		{"Nested", Value{Typ: "array", Array: []Value{bulk("a"), NewCommandValue("b", "c")}}, "a\nb\nc\n"},
+		{"Map", NewMapValue([]MapEntry{{Key: bulk("k1"), Value: bulk("v1")}, {Key: bulk("k2"), Value: NewDoubleValue(1.5)}}), "k1\nv1\nk2\n1.5\n"},
+		{"Boolean", NewBooleanValue(false), "(false)\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := FormatReplyRaw(tc.value)
+			if result != tc.expected {
+				t.Errorf("FormatReplyRaw() = %q, want %q", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestQuote_RoundTripsWithSplitArgs(t *testing.T) {
+	for _, s := range []string{"", "plain", "with space", "\x00\x01\xff", "quote\"and\\slash", "\a\b\t\r\n"} {
+		args, err := SplitArgs(Quote(s))
+		if err != nil || len(args) != 1 || args[0] != s {
+			t.Errorf("SplitArgs(Quote(%q)) = %q (err %v)", s, args, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package goresp
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// Handler answers the commands of a server, like http.Handler it is called with a reply writer and the request
+type Handler interface {
+	ServeRESP(w ReplyWriter, r *Request)
+}
+
+// HandlerFunc turns a function into a Handler
+type HandlerFunc func(w ReplyWriter, r *Request)
+
+func (f HandlerFunc) ServeRESP(w ReplyWriter, r *Request) {
+	f(w, r)
+}
+
+// ReplyWriter builds the reply of a command, the replies are buffered and written to the client once the handler returns
+// a command is usually answered with one reply, an aggregate is written as its header followed by its elements
+type ReplyWriter interface {
+	WriteValue(v Value)
+	WriteSimpleString(s string)
+	WriteError(message string)
+	WriteInt(n int64)
+	WriteBulk(s string)
+	WriteNull()
+	WriteArrayHeader(length int)
+}
+
+// Request is a command received by a server
+type Request struct {
+	Args []string     // the command name followed by its arguments, as the client sent them
+	Info *CommandInfo // the command matched by ServeMux, nil until the request is routed
+	Conn *Conn        // the connection the command was read from, nil for a request built with NewRequest
+
+	ctx context.Context
+}
+
+// NewRequest builds a request for args, servers use it and so can tests of handlers
+func NewRequest(ctx context.Context, args ...string) *Request {
+	return &Request{Args: args, ctx: ctx}
+}
+
+// Context returns the context of the request, it is cancelled when the server closes the connection
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// replyBuffer implements ReplyWriter with the Append functions
+type replyBuffer struct {
+	buf []byte
+}
+
+func (b *replyBuffer) WriteValue(v Value)          { b.buf = v.AppendMarshal(b.buf) }
+func (b *replyBuffer) WriteSimpleString(s string)  { b.buf = AppendSimpleString(b.buf, s) }
+func (b *replyBuffer) WriteError(message string)   { b.buf = AppendError(b.buf, message) }
+func (b *replyBuffer) WriteInt(n int64)            { b.buf = AppendInt(b.buf, n) }
+func (b *replyBuffer) WriteBulk(s string)          { b.buf = AppendBulk(b.buf, s) }
+func (b *replyBuffer) WriteNull()                  { b.buf = AppendNull(b.buf) }
+func (b *replyBuffer) WriteArrayHeader(length int) { b.buf = AppendArrayHeader(b.buf, length) }
+
+// ReplyRecorder is a ReplyWriter that keeps the replies in memory, it lets handlers be tested without a server
+type ReplyRecorder struct {
+	replyBuffer
+}
+
+// Bytes returns the encoded replies written so far
+func (r *ReplyRecorder) Bytes() []byte {
+	return r.buf
+}
+
+// Values decodes the replies written so far
+func (r *ReplyRecorder) Values() ([]Value, error) {
+	reader := NewRespIo(bytes.NewReader(r.buf))
+	var values []Value
+	for {
+		v, err := reader.Read()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+}
@@ -0,0 +1,55 @@
+package goresp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// returned by readInline for a line without arguments, Read skips it
+var errBlankInline = errors.New("blank inline command")
+
+// reads an inline command, the first byte of the line was already consumed by Read
+// like redis the line may end with a lone '\n', blank lines are skipped and the arguments are split with SplitArgs
+func (r *RespIo) readInline(start int64) (Value, error) {
+	max := r.opts.MaxInlineLen
+	if max <= 0 {
+		max = DefaultMaxInlineLen
+	}
+
+	// give the first byte back so the whole line is read at once
+	r.reader.UnreadByte()
+	r.offset--
+
+	var line []byte
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		r.offset += int64(len(chunk))
+		if len(line) > max+2 {
+			return Value{}, r.protocolError(start, ErrLineTooLong, fmt.Sprintf("an inline command of at most %d bytes", max), nil)
+		}
+		if err := r.checkValueSize(0); err != nil {
+			return Value{}, err
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return Value{}, r.ioError(err)
+		}
+		break
+	}
+
+	args, err := SplitArgs(string(bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})))
+	if err != nil {
+		return Value{}, r.protocolError(start, err, "an inline command", line)
+	}
+
+	if len(args) == 0 {
+		return Value{}, errBlankInline
+	}
+
+	return NewCommandValue(args...), nil
+}
@@ -0,0 +1,144 @@
+package goresp
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func inlineReader(input string) *RespIo {
+	return NewRespIoWithOptions(strings.NewReader(input), ReaderOptions{Inline: true})
+}
+
+func TestRespIo_Inline_Commands(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"Ping", "PING\r\n", []string{"PING"}},
+		{"LoneLF", "PING\n", []string{"PING"}},
+		{"Arguments", "SET a 1\r\n", []string{"SET", "a", "1"}},
+		{"Quotes", "SET greeting \"hello world\"\r\n", []string{"SET", "greeting", "hello world"}},
+		{"LeadingBlanks", "  GET  key \r\n", []string{"GET", "key"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := inlineReader(tc.input).Read()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			expected := NewCommandValue(tc.expected...)
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, result)
+			}
+		})
+	}
+}
+
+func TestRespIo_Inline_MixedWithRESP(t *testing.T) {
+	input := "PING\r\n\r\n\n*2\r\n$3\r\nGET\r\n$1\r\na\r\nECHO hi\n"
+	reader := inlineReader(input)
+
+	expected := []Value{
+		NewCommandValue("PING"),
+		NewCommandValue("GET", "a"),
+		NewCommandValue("ECHO", "hi"),
+	}
+
+	for i, e := range expected {
+		result, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Read %d: expected no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(result, e) {
+			t.Errorf("Read %d: expected %+v, got %+v", i, e, result)
+		}
+	}
+
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	if reader.Offset() != int64(len(input)) {
+		t.Errorf("Expected offset %d, got %d", len(input), reader.Offset())
+	}
+}
+
+func TestRespIo_Inline_PrefixCharacters(t *testing.T) {
+	lines := []string{"+incr a", "-1 b", ":5", "$3 x", "_", "#t", ",1.5", "%2", "~set", ">push", "|attr", "(123", "=txt", "!err"}
+	reader := inlineReader(strings.Join(lines, "\r\n") + "\r\n")
+
+	for _, line := range lines {
+		result, err := reader.Read()
+		if err != nil || !reflect.DeepEqual(result, NewCommandValue(strings.Fields(line)...)) {
+			t.Errorf("Read %q: expected an inline command, got %+v (err %v)", line, result, err)
+		}
+	}
+}
+
+func TestRespIo_Inline_Disabled(t *testing.T) {
+	_, err := NewRespIo(strings.NewReader("PING\r\n")).Read()
+
+	if !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected ErrUnknownType without the Inline option, got %v", err)
+	}
+}
+
+func TestRespIo_Inline_NotInsideAggregates(t *testing.T) {
+	_, err := inlineReader("*1\r\nPING\r\n").Read()
+
+	if !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected ErrUnknownType for an inline command nested in an array, got %v", err)
+	}
+}
+
+func TestRespIo_Inline_UnbalancedQuotes(t *testing.T) {
+	_, err := inlineReader("SET a \"b\r\n").Read()
+
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrUnbalancedQuotes) {
+		t.Errorf("Expected a ProtocolError wrapping ErrUnbalancedQuotes, got %v", err)
+	}
+}
+
+func TestRespIo_Inline_MaxLength(t *testing.T) {
+	line := "SET key " + strings.Repeat("v", DefaultMaxInlineLen) + "\r\n"
+
+	_, err := inlineReader(line).Read()
+	if !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("Expected ErrLineTooLong with the default limit, got %v", err)
+	}
+
+	reader := NewRespIoWithOptions(strings.NewReader("SET key value\r\n"), ReaderOptions{Inline: true, MaxInlineLen: 8})
+	if _, err := reader.Read(); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("Expected ErrLineTooLong with MaxInlineLen, got %v", err)
+	}
+}
+
+func TestRespIo_Inline_Truncated(t *testing.T) {
+	_, err := inlineReader("PING").Read()
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestViewReader_Inline(t *testing.T) {
+	reader := NewViewReaderWithOptions(strings.NewReader("SET a 1\r\n+OK\r\n"), ReaderOptions{Inline: true})
+
+	result, err := reader.Read()
+	if err != nil || !reflect.DeepEqual(result, NewCommandValue("SET", "a", "1")) {
+		t.Errorf("Expected SET a 1, got %+v (err %v)", result, err)
+	}
+
+	// only '*' starts a RESP value at the top level
+	result, err = reader.Read()
+	if err != nil || !reflect.DeepEqual(result, NewCommandValue("+OK")) {
+		t.Errorf("Expected the inline command +OK, got %+v (err %v)", result, err)
+	}
+}
@@ -2,58 +2,213 @@ package goresp
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"slices"
 	"strconv"
-	"strings"
 )
 
+// ReaderOptions are the safety limits RespIo applies to the values it reads, a zero field means no limit
+// when a limit is exceeded Read returns a ProtocolError wrapping ErrLimitExceeded and the stream can't be read any further
+type ReaderOptions struct {
+	MaxBulkLen    int64 // the largest payload of bulk strings, verbatim strings and blob errors, like redis proto-max-bulk-len
+	MaxArrayLen   int64 // the most elements of an array, set or push and the most pairs of a map or attribute
+	MaxDepth      int   // the most aggregates nested inside each other
+	MaxInlineLen  int   // the longest line of the line based types (simple strings, errors, integers, doubles...)
+	MaxValueBytes int64 // the most bytes a single top level value can span on the wire
+
+	// Inline lets a top level value be a plain text command line like telnet or nc send, it is read as an array of bulk strings
+	// like in redis every top level value that doesn't start with '*' is read as an inline command
+	// the line is limited to MaxInlineLen bytes, or to DefaultMaxInlineLen when MaxInlineLen is 0
+	Inline bool
+}
+
+// the default limit on bulk strings, it matches the default proto-max-bulk-len of redis
+const DefaultMaxBulkLen = 512 * 1024 * 1024
+
+// the default limit on nesting, far beyond what any redis reply uses
+const DefaultMaxDepth = 512
+
+// the default limit on inline commands, it matches PROTO_INLINE_MAX_SIZE of redis
+const DefaultMaxInlineLen = 64 * 1024
+
+// DefaultReaderOptions returns the limits used by NewRespIo
+func DefaultReaderOptions() ReaderOptions {
+	return ReaderOptions{MaxBulkLen: DefaultMaxBulkLen, MaxDepth: DefaultMaxDepth}
+}
+
 type RespIo struct {
-	reader *bufio.Reader
+	reader     *bufio.Reader
+	opts       ReaderOptions
+	offset     int64 // number of bytes consumed from the stream, used to locate protocol errors
+	valueStart int64 // offset of the top level value being read
+	prefix     byte  // type prefix of the value being read
+	depth      int   // how many aggregates are open, an EOF inside one is unexpected
+
+	arena *viewArena // when set the strings and slices of the values are carved from it, see ViewReader
+
+	stream *BulkReader // the bulk returned by ReadStream that is still open
 }
 
 func NewRespIo(rd io.Reader) *RespIo {
-	return &RespIo{reader: bufio.NewReader(rd)}
+	return NewRespIoWithOptions(rd, DefaultReaderOptions())
+}
+
+// NewRespIoWithOptions creates a RespIo that enforces the given limits, use it for untrusted peers
+func NewRespIoWithOptions(rd io.Reader, opts ReaderOptions) *RespIo {
+	return &RespIo{reader: bufio.NewReader(rd), opts: opts}
+}
+
+// Offset returns the number of bytes consumed from the underlying reader so far
+func (r *RespIo) Offset() int64 {
+	return r.offset
+}
+
+// the number of bytes already received and waiting in the buffer, the server uses it to batch the replies of a pipeline
+func (r *RespIo) buffered() int {
+	return r.reader.Buffered()
+}
+
+// builds a ProtocolError for the value being read
+func (r *RespIo) protocolError(offset int64, err error, expected string, got []byte) error {
+	return &ProtocolError{Offset: offset, Prefix: r.prefix, Expected: expected, Got: string(got), Err: err}
+}
+
+// an EOF in the middle of a value means the stream was truncated
+func (r *RespIo) ioError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return r.protocolError(r.offset, io.ErrUnexpectedEOF, "more data", nil)
+	}
+	return err
+}
+
+// checks that consuming pending more bytes keeps the top level value under MaxValueBytes
+func (r *RespIo) checkValueSize(pending int64) error {
+	max := r.opts.MaxValueBytes
+	if max > 0 && r.offset-r.valueStart+pending > max {
+		return r.protocolError(r.valueStart, ErrValueTooLarge, fmt.Sprintf("a value of at most %d bytes", max), nil)
+	}
+	return nil
+}
+
+// checks the length and the nesting of an aggregate before its elements are read
+func (r *RespIo) checkAggregate(start int64, length int) error {
+	if max := r.opts.MaxArrayLen; max > 0 && int64(length) > max {
+		return r.protocolError(start, ErrArrayTooLong, fmt.Sprintf("at most %d elements", max), []byte(strconv.Itoa(length)))
+	}
+	if max := r.opts.MaxDepth; max > 0 && r.depth >= max {
+		return r.protocolError(start, ErrTooDeep, fmt.Sprintf("at most %d nested aggregates", max), nil)
+	}
+	return nil
 }
 
 // it reads aline from the underlying reader, stooping at '\r and returning the line without the  trailing '\r\n'
 // it returns the line m number of bytes read,
 func (r *RespIo) readLine() (line []byte, numBytes int, err error) {
+	if r.arena != nil {
+		return r.readLineSlice()
+	}
+
+	start := r.offset
 	for {
 		b, err := r.reader.ReadByte()
 		if err != nil {
-			return nil, 0, err
+			return nil, numBytes, r.ioError(err)
 		}
+		r.offset++
 		numBytes += 1
+		// the 2 extra bytes are the CRLF
+		if max := r.opts.MaxInlineLen; max > 0 && numBytes > max+2 {
+			return nil, numBytes, r.protocolError(start, ErrLineTooLong, fmt.Sprintf("a line of at most %d bytes", max), nil)
+		}
+		if err := r.checkValueSize(0); err != nil {
+			return nil, numBytes, err
+		}
 		line = append(line, b)
-		if len(line) >= 2 && line[len(line)-2] == '\r' {
+		// the line ends at the first '\n' or right after a '\r', either way it must end with CRLF like in readLineSlice
+		if b == '\n' || (len(line) >= 2 && line[len(line)-2] == '\r') {
 			break
 		}
 	}
 
+	if len(line) < 2 || line[len(line)-2] != '\r' || line[len(line)-1] != '\n' {
+		return nil, numBytes, r.protocolError(start, ErrMissingCRLF, "CRLF at the end of the line", line)
+	}
+
 	return line[:len(line)-2], numBytes, nil
 }
 
 // it reads  and parses the number value  used to parse the length of the bulk string $<length>\r\n<data>\r\n
 func (r *RespIo) readInteger() (x int, n int, err error) {
+	start := r.offset
 	line, n, err := r.readLine()
-	if err != nil {
-		return 0, 0, err
-	}
-	i64, err := strconv.ParseInt(string(line), 10, 64)
 	if err != nil {
 		return 0, n, err
 	}
+	i64, ok := parseCanonicalInt(line)
+	if !ok {
+		return 0, n, r.protocolError(start, ErrInvalidLength, "an integer length", line)
+	}
 	return int(i64), n, nil
 }
 
+// lengths are only accepted the way redis writes them, without a sign, spaces or leading zeros,
+// so every value that is read marshals back to the same bytes
+func parseCanonicalInt(line []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(line), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	var buf [20]byte
+	return n, bytes.Equal(strconv.AppendInt(buf[:0], n, 10), line)
+}
+
 // the main function the triggers the reading process on the io   resp and it returns a Value object
 func (r *RespIo) Read() (Value, error) {
+	for {
+		v, err := r.readValue()
+		// blank inline lines are skipped like redis does
+		if err != errBlankInline {
+			return v, err
+		}
+	}
+}
+
+// reads a single value of any type
+func (r *RespIo) readValue() (Value, error) {
+	// a bulk left open by ReadStream must be skipped before the next value
+	if r.stream != nil && r.depth == 0 {
+		if err := r.stream.Close(); err != nil {
+			return Value{}, err
+		}
+	}
+
+	start := r.offset
+	if r.depth == 0 {
+		r.valueStart = start
+		if r.arena != nil {
+			r.arena.reset()
+		}
+	}
 	_type, err := r.reader.ReadByte()
 
 	if err != nil {
-		return Value{}, err
+		// a clean EOF between two values is returned as is so callers can detect the end of the stream
+		if r.depth == 0 {
+			return Value{}, err
+		}
+		return Value{}, r.ioError(err)
 	}
+	r.offset++
+	r.prefix = _type
+
+	// like processInputBuffer of redis, a request that doesn't start with '*' is an inline command whatever its first byte
+	if r.opts.Inline && r.depth == 0 && _type != ARRAY {
+		r.prefix = 0
+		return r.readInline(start)
+	}
+
 	switch _type {
 	case ARRAY:
 		return r.readArray()
@@ -67,27 +222,89 @@ func (r *RespIo) Read() (Value, error) {
 		return r.readError()
 	case NULL:
 		return r.readNull()
+	case DOUBLE:
+		return r.readDouble()
+	case BOOLEAN:
+		return r.readBoolean()
+	case BIGNUMBER:
+		return r.readBigNumber()
+	case VERBATIM:
+		return r.readVerbatim()
+	case BLOBERROR:
+		return r.readBlobError()
+	case MAP:
+		return r.readMap()
+	case SET:
+		return r.readSet()
+	case PUSH:
+		return r.readPush()
+	case ATTRIBUTE:
+		return r.readAttribute()
 	default:
-		fmt.Printf("Unknown type: %v", string(_type))
-		return Value{}, nil
+		r.prefix = 0
+		return Value{}, r.protocolError(start, ErrUnknownType, "a type prefix", []byte{_type})
 	}
 }
 
 // reads and returns Value of type Array
 func (r *RespIo) readArray() (Value, error) {
+	return r.readAggregate(KindArray, KindNullArray)
+}
+
+// reads and returns Value of type set, it has the same layout as the array
+func (r *RespIo) readSet() (Value, error) {
+	return r.readAggregate(KindSet, "")
+}
+
+// reads and returns Value of type push, it has the same layout as the array
+func (r *RespIo) readPush() (Value, error) {
+	return r.readAggregate(KindPush, "")
+}
+
+// reads the length header of a bulk or an aggregate, -1 is only accepted when nullable is true and it is reported by null
+func (r *RespIo) readLength(nullable bool) (length int, null bool, err error) {
+	start := r.offset
+	length, _, err = r.readInteger()
+	if err != nil {
+		return 0, false, err
+	}
+	if nullable && length == -1 {
+		return 0, true, nil
+	}
+	if length < 0 {
+		return 0, false, r.protocolError(start, ErrInvalidLength, "a non negative length", []byte(strconv.Itoa(length)))
+	}
+
+	return length, false, nil
+}
+
+// reads the length header and then the elements of an array like type (array, set, push)
+// when nullTyp is not empty a -1 length is returned as a Value of that type
+func (r *RespIo) readAggregate(typ Kind, nullTyp Kind) (Value, error) {
 
 	v := Value{}
-	v.Typ = "array"
+	v.Typ = typ
 
 	// read length of Array
-	length, _, err := r.readInteger()
+	start := r.offset
+	length, null, err := r.readLength(nullTyp != "")
 	if err != nil {
 		return v, err
 	}
+	if null {
+		v.Typ = nullTyp
+		return v, nil
+	}
+	if err := r.checkAggregate(start, length); err != nil {
+		return v, err
+	}
 
-	// foreach line, parse and read the value
-	if length < 0 {
-		return v, fmt.Errorf("Array length cant be negative")
+	r.depth++
+	defer func() { r.depth-- }()
+
+	if r.arena != nil {
+		v.Array, err = r.readValuesView(length)
+		return v, err
 	}
 
 	v.Array = make([]Value, 0)
@@ -104,62 +321,232 @@ func (r *RespIo) readArray() (Value, error) {
 	return v, nil
 }
 
+// reads the key value pairs of a map or an attribute
+func (r *RespIo) readPairs() ([]MapEntry, error) {
+	start := r.offset
+	length, _, err := r.readLength(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkAggregate(start, length); err != nil {
+		return nil, err
+	}
+
+	r.depth++
+	defer func() { r.depth-- }()
+
+	if r.arena != nil {
+		return r.readPairsView(length)
+	}
+
+	entries := make([]MapEntry, 0)
+	for i := 0; i < length; i++ {
+		key, err := r.Read()
+		if err != nil {
+			return entries, err
+		}
+		val, err := r.Read()
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, MapEntry{Key: key, Value: val})
+	}
+
+	return entries, nil
+}
+
+// reads and returns Value of type map
+func (r *RespIo) readMap() (Value, error) {
+	v := Value{}
+	v.Typ = KindMap
+
+	entries, err := r.readPairs()
+	v.Map = entries
+	if err != nil {
+		return v, err
+	}
+
+	return v, nil
+}
+
+// reads the attribute map and then the reply it describes, the attributes are attached to that reply
+func (r *RespIo) readAttribute() (Value, error) {
+	attrs, err := r.readPairs()
+	if err != nil {
+		return Value{}, err
+	}
+
+	// the attribute is not a value on its own, the reply it describes must follow
+	start := r.offset
+	r.depth++
+	v, err := r.Read()
+	r.depth--
+	if err != nil {
+		return v, err
+	}
+	// two attributes in a row would be merged into one and marshal differently
+	if v.Attrs != nil {
+		return v, r.protocolError(start, ErrInvalidValue, "a reply after the attribute", []byte{ATTRIBUTE})
+	}
+	v.Attrs = attrs
+
+	return v, nil
+}
+
 // reads and returns Value of type Bulk
 func (r *RespIo) readBulk() (Value, error) {
 	v := Value{}
 
-	v.Typ = "bulk"
+	v.Typ = KindBulk
 
-	length, _, err := r.readInteger()
+	length, null, err := r.readLength(true)
+	if err != nil {
+		return v, err
+	}
+	// $-1 is the null bulk string, it is what redis replies for a missing key
+	if null {
+		v.Typ = KindNull
+		return v, nil
+	}
+
+	bulk, err := r.readPayload(length)
 	if err != nil {
 		return v, err
 	}
-	if length < 0 {
 
-		return v, fmt.Errorf("Bulk length cant be negative")
+	v.Bulk = r.payloadString(bulk)
 
+	return v, nil
+}
+
+// reads and returns Value of type verbatim string =<length>\r\n<format>:<data>\r\n
+func (r *RespIo) readVerbatim() (Value, error) {
+	v := Value{}
+
+	v.Typ = KindVerbatim
+
+	start := r.offset
+	blob, err := r.readBlob()
+	if err != nil {
+		return v, err
+	}
+	if len(blob) < 4 || blob[3] != ':' {
+		return v, r.protocolError(start, ErrInvalidValue, "a 3 bytes format followed by ':'", blob)
 	}
 
-	Bulk := make([]byte, length)
+	v.Format = r.payloadString(blob[:3])
+	v.Bulk = r.payloadString(blob[4:])
 
-	// to handle the extreme case if the length is bigger than 4096 which  the internal buffer for the bufio read
-	totalRead := 0
-	for totalRead < length {
-		n, err := r.reader.Read(Bulk[totalRead:])
-		if err != nil {
-			return v, fmt.Errorf("Error reading bulk data: %v", err)
-		}
-		if n == 0 {
-			return v, fmt.Errorf("Unexpected EOF: read %d bytes, expected %d bytes", totalRead, length)
-		}
-		totalRead += n
+	return v, nil
+}
+
+// reads and returns Value of type blob error, the message is kept in Str like simple errors
+func (r *RespIo) readBlobError() (Value, error) {
+	v := Value{}
+
+	v.Typ = KindBlobError
+
+	blob, err := r.readBlob()
+	if err != nil {
+		return v, err
 	}
 
+	v.Str = r.payloadString(blob)
+
+	return v, nil
+}
+
+// reads a length prefixed payload and its trailing CRLF
+func (r *RespIo) readBlob() ([]byte, error) {
+	length, _, err := r.readLength(false)
 	if err != nil {
+		return nil, err
+	}
 
-		return v, err
+	return r.readPayload(length)
+}
+
+// reads length bytes of data and the trailing CRLF
+// the limits are checked before anything is allocated as the length comes from the wire
+func (r *RespIo) readPayload(length int) ([]byte, error) {
+	if max := r.opts.MaxBulkLen; max > 0 && int64(length) > max {
+		return nil, r.protocolError(r.offset, ErrBulkTooLarge, fmt.Sprintf("a bulk of at most %d bytes", max), []byte(strconv.Itoa(length)))
+	}
+	if err := r.checkValueSize(int64(length) + 2); err != nil {
+		return nil, err
 	}
 
-	v.Bulk = string(Bulk)
+	var Bulk []byte
+	if length > maxPayloadPrealloc {
+		var err error
+		if Bulk, err = r.readLargePayload(length); err != nil {
+			return nil, err
+		}
+	} else {
+		Bulk = r.alloc(length)
+
+		// ReadFull handles the extreme case if the length is bigger than 4096 which  the internal buffer for the bufio read
+		n, err := io.ReadFull(r.reader, Bulk)
+		r.offset += int64(n)
+		if err != nil {
+			return nil, r.ioError(err)
+		}
+	}
 
 	// Read the trailing CRLF
-	line, _, err := r.readLine()
-	if err != nil {
-		return v, fmt.Errorf("Error reading trailing CRLF: %v", err)
+	if err := r.readCRLF(); err != nil {
+		return nil, err
+	}
+
+	return Bulk, nil
+}
+
+// payloads up to this size are allocated at once, longer ones grow with the data that actually arrives
+// so a peer announcing a huge bulk it never sends can't make the reader allocate it
+const maxPayloadPrealloc = 64 * 1024
+
+// reads a payload longer than maxPayloadPrealloc doubling the buffer as the data arrives,
+// it is not carved from the view arena which would keep such a large buffer around
+func (r *RespIo) readLargePayload(length int) ([]byte, error) {
+	buf := make([]byte, 0, maxPayloadPrealloc)
+	for len(buf) < length {
+		if len(buf) == cap(buf) {
+			buf = slices.Grow(buf, min(len(buf), length-len(buf)))
+		}
+		n, err := io.ReadFull(r.reader, buf[len(buf):min(cap(buf), length)])
+		buf = buf[:len(buf)+n]
+		r.offset += int64(n)
+		if err != nil {
+			return nil, r.ioError(err)
+		}
 	}
-	if string(line) != "" {
-		return v, fmt.Errorf("Expected CRLF after bulk data, but got '%s'", line)
+
+	return buf, nil
+}
+
+// reads the CRLF that terminates the payload of bulk like types
+func (r *RespIo) readCRLF() error {
+	start := r.offset
+	for _, expected := range []byte{'\r', '\n'} {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return r.ioError(err)
+		}
+		r.offset++
+		if b != expected {
+			return r.protocolError(start, ErrMissingCRLF, "CRLF after bulk data", []byte{b})
+		}
 	}
 
-	return v, nil
+	return nil
 }
 
 // reads and returns Value of type simple string
 func (r *RespIo) readString() (Value, error) {
 	line, _, err := r.readLine()
 	v := Value{}
-	v.Typ = "string"
-	v.Str = string(line)
+	v.Typ = KindString
+	v.Str = r.lineString(line)
 
 	if err != nil {
 		return v, err
@@ -171,23 +558,32 @@ func (r *RespIo) readString() (Value, error) {
 func (r *RespIo) readNumber() (Value, error) {
 	v := Value{}
 
-	v.Typ = "integer"
+	v.Typ = KindInt
 
+	start := r.offset
 	line, _, err := r.readLine()
-
-	n, err := strconv.Atoi(strings.TrimSpace(string(line)))
 	if err != nil {
 		return v, err
 	}
 
+	n, err := strconv.Atoi(string(bytes.TrimSpace(line)))
+	if err != nil {
+		return v, r.protocolError(start, ErrInvalidValue, "an integer", line)
+	}
+
 	v.Num = int64(n)
+	// an integer written differently than AppendInt would, like "+5" or "007", keeps its text so it marshals back the same
+	var buf [20]byte
+	if !bytes.Equal(strconv.AppendInt(buf[:0], v.Num, 10), line) {
+		v.Str = r.lineString(line)
+	}
 	return v, nil
 }
 
 func (r *RespIo) readError() (Value, error) {
 	v := Value{}
 
-	v.Typ = "error"
+	v.Typ = KindError
 
 	line, _, err := r.readLine()
 
@@ -195,15 +591,104 @@ func (r *RespIo) readError() (Value, error) {
 		return v, err
 	}
 
-	v.Str = string(line)
+	v.Str = r.lineString(line)
 	return v, nil
 
 }
 
 func (r *RespIo) readNull() (Value, error) {
 	v := Value{}
-	r.readLine()
-	v.Typ = "null"
+	v.Typ = KindNull
+
+	start := r.offset
+	line, _, err := r.readLine()
+	if err != nil {
+		return v, err
+	}
+	if len(line) != 0 {
+		return v, r.protocolError(start, ErrInvalidValue, "an empty null", line)
+	}
+
+	v.Resp3Null = true
+	return v, nil
+}
+
+// reads and returns Value of type double, inf, -inf and nan are accepted
+func (r *RespIo) readDouble() (Value, error) {
+	v := Value{}
+
+	v.Typ = KindDouble
+
+	start := r.offset
+	line, _, err := r.readLine()
+	if err != nil {
+		return v, err
+	}
+
+	f, err := strconv.ParseFloat(string(line), 64)
+	if err != nil {
+		return v, r.protocolError(start, ErrInvalidValue, "a double", line)
+	}
+
+	v.Double = f
+	// same as integers, "1.0" or "1e3" keep their text
+	var buf [32]byte
+	canonical := appendDouble(buf[:0], f)
+	if !bytes.Equal(canonical[1:len(canonical)-2], line) {
+		v.Str = r.lineString(line)
+	}
+	return v, nil
+}
+
+// reads and returns Value of type boolean #t\r\n or #f\r\n
+func (r *RespIo) readBoolean() (Value, error) {
+	v := Value{}
+
+	v.Typ = KindBoolean
+
+	start := r.offset
+	line, _, err := r.readLine()
+	if err != nil {
+		return v, err
+	}
+
+	switch string(line) {
+	case "t":
+		v.Bool = true
+	case "f":
+		v.Bool = false
+	default:
+		return v, r.protocolError(start, ErrInvalidValue, "'t' or 'f'", line)
+	}
+
+	return v, nil
+}
+
+// reads and returns Value of type big number, the digits are kept as is in Str
+func (r *RespIo) readBigNumber() (Value, error) {
+	v := Value{}
+
+	v.Typ = KindBigNumber
+
+	start := r.offset
+	line, _, err := r.readLine()
+	if err != nil {
+		return v, err
+	}
+
+	digits := line
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 {
+		return v, r.protocolError(start, ErrInvalidValue, "a big number", line)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return v, r.protocolError(start, ErrInvalidValue, "a big number", line)
+		}
+	}
 
+	v.Str = r.lineString(line)
 	return v, nil
 }
@@ -1,9 +1,13 @@
 package goresp
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/rand/v2"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
@@ -90,7 +94,7 @@ func TestRespIo_readArray_NestedArrays(t *testing.T) {
 		t.Errorf("Expected first nested array length 3, got %d", len(firstNestedArray.Array))
 	}
 	for i, v := range firstNestedArray.Array {
-		if v.Typ != "integer" || v.Num != int64(i+1) {
+		if v.Typ != "int" || v.Num != int64(i+1) {
 			t.Errorf("Expected element %d to be number %d, got type %s and value %d", i, i+1, v.Typ, v.Num)
 		}
 	}
@@ -122,9 +126,32 @@ func TestRespIo_readArray_NegativeLength(t *testing.T) {
 		t.Error("Expected an error for negative array length, but got nil")
 	}
 
-	expectedError := "Array length cant be negative"
-	if err.Error() != expectedError {
-		t.Errorf("Expected error message '%s', but got '%s'", expectedError, err.Error())
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Expected a ProtocolError wrapping ErrInvalidLength, but got '%v'", err)
+	}
+
+	if protocolErr.Offset != 0 || protocolErr.Got != "-5" {
+		t.Errorf("Expected offset 0 and got '-5', but got offset %d and got '%s'", protocolErr.Offset, protocolErr.Got)
+	}
+}
+
+func TestRespIo_readArray_NullArray(t *testing.T) {
+	input := "-1\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	result, err := reader.readArray()
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if result.Typ != "nullarray" {
+		t.Errorf("Expected type to be 'nullarray', got %s", result.Typ)
+	}
+
+	if result.Array != nil {
+		t.Errorf("Expected nil array, got %v", result.Array)
 	}
 }
 
@@ -148,7 +175,7 @@ func TestRespIo_readArray_MixedDataTypes(t *testing.T) {
 	}
 
 	// Check integer
-	if result.Array[0].Typ != "integer" || result.Array[0].Num != 42 {
+	if result.Array[0].Typ != "int" || result.Array[0].Num != 42 {
 		t.Errorf("Expected first element to be integer 42, got type %s and value %d", result.Array[0].Typ, result.Array[0].Num)
 	}
 
@@ -197,7 +224,7 @@ func TestRespIo_readArray_UnexpectedEOF(t *testing.T) {
 
 	expectedValues := []int64{1, 2}
 	for i, v := range result.Array {
-		if v.Typ != "integer" || v.Num != expectedValues[i] {
+		if v.Typ != "int" || v.Num != expectedValues[i] {
 			t.Errorf("Expected element %d to be number %d, got type %s and value %d", i, expectedValues[i], v.Typ, v.Num)
 		}
 	}
@@ -222,7 +249,7 @@ func TestRespIo_readArray_WithNullValues(t *testing.T) {
 	}
 
 	expectedValues := []struct {
-		typ  string
+		typ  Kind
 		bulk string
 	}{
 		{"bulk", "test"},
@@ -299,9 +326,69 @@ func TestRespIo_readBulk_NegativeLength(t *testing.T) {
 		t.Error("Expected an error for negative bulk string length, but got nil")
 	}
 
-	expectedError := "Bulk length cant be negative"
-	if err.Error() != expectedError {
-		t.Errorf("Expected error message '%s', but got '%s'", expectedError, err.Error())
+	if !errors.Is(err, ErrInvalidLength) {
+		t.Errorf("Expected error to wrap ErrInvalidLength, but got '%v'", err)
+	}
+}
+
+func TestRespIo_readBulk_NullBulk(t *testing.T) {
+	input := "-1\r\n+OK\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	result, err := reader.readBulk()
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if result.Typ != "null" {
+		t.Errorf("Expected type to be 'null', got %s", result.Typ)
+	}
+
+	// the null bulk has no payload so the next value must follow directly
+	next, err := reader.Read()
+	if err != nil || next.Typ != "string" || next.Str != "OK" {
+		t.Errorf("Expected next value to be string 'OK', got %+v (err %v)", next, err)
+	}
+}
+
+func TestRespIo_Read_NullsRoundTrip(t *testing.T) {
+	for _, input := range []string{"$-1\r\n", "*-1\r\n"} {
+		reader := NewRespIo(strings.NewReader(input))
+
+		result, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Expected no error for %q, got %v", input, err)
+		}
+
+		if got := string(result.Marshal()); got != input {
+			t.Errorf("Expected round trip to return %q, got %q", input, got)
+		}
+	}
+}
+
+func TestRespIo_Read_IntegerRoundTrip(t *testing.T) {
+	input := "*5\r\n:42\r\n:-7\r\n:+5\r\n:007\r\n: 3\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	result, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := string(result.Marshal()); got != input {
+		t.Errorf("Expected round trip to return %q, got %q", input, got)
+	}
+}
+
+func TestRespIo_readSet_NegativeLength(t *testing.T) {
+	input := "-1\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	_, err := reader.readSet()
+
+	if err == nil {
+		t.Error("Expected an error for negative set length, but got nil")
 	}
 }
 
@@ -364,9 +451,14 @@ func TestRespIo_readBulk_LengthMismatch(t *testing.T) {
 		t.Error("Expected an error for length mismatch, but got nil")
 	}
 
-	expectedError := "Error reading trailing CRLF: EOF"
-	if !strings.Contains(err.Error(), expectedError) {
-		t.Errorf("Expected error message to contain '%s', but got '%s'", expectedError, err.Error())
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrMissingCRLF) {
+		t.Fatalf("Expected a ProtocolError wrapping ErrMissingCRLF, but got '%v'", err)
+	}
+
+	// 3 bytes of header and 6 bytes of data are consumed before the CRLF is expected
+	if protocolErr.Offset != 9 {
+		t.Errorf("Expected offset 9, but got %d", protocolErr.Offset)
 	}
 }
 
@@ -428,8 +520,8 @@ func TestRespIo_readBulk_EOFBeforeCompleteRead(t *testing.T) {
 		t.Error("Expected an error when EOF is reached before reading the entire bulk string, but got nil")
 	}
 
-	if err == errors.New("Error reading bulk data: EOF") {
-		t.Errorf("Expected EOF error, got: %v", err)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected unexpected EOF error, got: %v", err)
 	}
 }
 
@@ -631,7 +723,7 @@ func TestRespIo_readString_ImmediateEOF(t *testing.T) {
 		t.Error("Expected an error, got nil")
 	}
 
-	if !errors.Is(err, io.EOF) {
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
 		t.Errorf("Expected EOF error, got: %v", err)
 	}
 
@@ -682,8 +774,8 @@ func TestRespIo_readNumber_ValidPositiveInteger(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if result.Typ != "integer" {
-		t.Errorf("Expected type to be 'integer', got %s", result.Typ)
+	if result.Typ != "int" {
+		t.Errorf("Expected type to be 'int', got %s", result.Typ)
 	}
 
 	if result.Num != 42 {
@@ -700,8 +792,8 @@ func TestRespIo_readNumber_ValidNegativeInteger(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if result.Typ != "integer" {
-		t.Errorf("Expected type to be 'integer', got %s", result.Typ)
+	if result.Typ != "int" {
+		t.Errorf("Expected type to be 'int', got %s", result.Typ)
 	}
 
 	expectedNum := int64(-42)
@@ -722,8 +814,8 @@ func TestRespIo_readNumber_Zero(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if result.Typ != "integer" {
-		t.Errorf("Expected type to be 'integer', got %s", result.Typ)
+	if result.Typ != "int" {
+		t.Errorf("Expected type to be 'int', got %s", result.Typ)
 	}
 
 	if result.Num != 0 {
@@ -740,9 +832,13 @@ func TestRespIo_readNumber_FloatingPoint(t *testing.T) {
 		t.Error("Expected an error for floating-point input, but got nil")
 	}
 
-	expectedError := "strconv.Atoi: parsing \"3.14\": invalid syntax"
-	if err.Error() != expectedError {
-		t.Errorf("Expected error message '%s', but got '%s'", expectedError, err.Error())
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Expected a ProtocolError wrapping ErrInvalidValue, but got '%v'", err)
+	}
+
+	if protocolErr.Got != "3.14" {
+		t.Errorf("Expected got to be '3.14', but got '%s'", protocolErr.Got)
 	}
 }
 func TestRespIo_readNumber_VeryLargeInteger(t *testing.T) {
@@ -755,8 +851,8 @@ func TestRespIo_readNumber_VeryLargeInteger(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if result.Typ != "integer" {
-		t.Errorf("Expected type to be 'integer', got %s", result.Typ)
+	if result.Typ != "int" {
+		t.Errorf("Expected type to be 'int', got %s", result.Typ)
 	}
 
 	expectedNum := int64(9223372036854775807)
@@ -774,8 +870,8 @@ func TestRespIo_readNumber_LeadingWhitespace(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if result.Typ != "integer" {
-		t.Errorf("Expected type to be 'integer', got %s", result.Typ)
+	if result.Typ != "int" {
+		t.Errorf("Expected type to be 'int', got %s", result.Typ)
 	}
 
 	expectedNum := int64(42)
@@ -794,17 +890,21 @@ func TestRespIo_readNumber_EmptyInput(t *testing.T) {
 		t.Error("Expected an error for empty input, but got nil")
 	}
 
-	if result.Typ != "integer" {
-		t.Errorf("Expected type to be 'integer', got %s", result.Typ)
+	if result.Typ != "int" {
+		t.Errorf("Expected type to be 'int', got %s", result.Typ)
 	}
 
 	if result.Num != 0 {
 		t.Errorf("Expected Num to be 0, got %d", result.Num)
 	}
 
-	expectedError := "strconv.Atoi: parsing \"\": invalid syntax"
-	if err.Error() != expectedError {
-		t.Errorf("Expected error message '%s', but got '%s'", expectedError, err.Error())
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Expected a ProtocolError wrapping ErrInvalidValue, but got '%v'", err)
+	}
+
+	if protocolErr.Got != "" {
+		t.Errorf("Expected got to be '', but got '%s'", protocolErr.Got)
 	}
 }
 func TestRespIo_readNumber_NonNumericInput(t *testing.T) {
@@ -817,16 +917,510 @@ func TestRespIo_readNumber_NonNumericInput(t *testing.T) {
 		t.Error("Expected an error for non-numeric input, but got nil")
 	}
 
-	if result.Typ != "integer" {
-		t.Errorf("Expected type to be 'integer', got %s", result.Typ)
+	if result.Typ != "int" {
+		t.Errorf("Expected type to be 'int', got %s", result.Typ)
 	}
 
 	if result.Num != 0 {
 		t.Errorf("Expected Num to be 0, got %d", result.Num)
 	}
 
-	expectedError := "strconv.Atoi: parsing \"abc\": invalid syntax"
-	if err.Error() != expectedError {
-		t.Errorf("Expected error message '%s', but got '%s'", expectedError, err.Error())
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Expected a ProtocolError wrapping ErrInvalidValue, but got '%v'", err)
+	}
+
+	if protocolErr.Got != "abc" {
+		t.Errorf("Expected got to be 'abc', but got '%s'", protocolErr.Got)
 	}
 }
+
+// Testing RESP3 types
+func TestRespIo_Read_RESP3RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"Double", ",3.14\r\n"},
+		{"NegativeDouble", ",-1.5\r\n"},
+		{"DoubleInf", ",inf\r\n"},
+		{"DoubleNegativeInf", ",-inf\r\n"},
+		{"DoubleNaN", ",nan\r\n"},
+		{"BooleanTrue", "#t\r\n"},
+		{"BooleanFalse", "#f\r\n"},
+		{"BigNumber", "(3492890328409238509324850943850943825024385\r\n"},
+		{"NegativeBigNumber", "(-3492890328409238509324850943850943825024385\r\n"},
+		{"Verbatim", "=15\r\ntxt:Some string\r\n"},
+		{"BlobError", "!21\r\nSYNTAX invalid syntax\r\n"},
+		{"Map", "%2\r\n+first\r\n#t\r\n+second\r\n$3\r\ntwo\r\n"},
+		{"EmptyMap", "%0\r\n"},
+		{"Set", "~3\r\n+orange\r\n+apple\r\n#t\r\n"},
+		{"Push", ">3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n"},
+		{"Attribute", "|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.1923\r\n*1\r\n+OK\r\n"},
+		{"Nested", "%1\r\n$4\r\nkeys\r\n~2\r\n=8\r\nmkd:# hi\r\n(12\r\n"},
+		{"Null", "_\r\n"},
+		{"NullInArray", "*2\r\n_\r\n$-1\r\n"},
+		{"DoubleWithZeroFraction", ",1.0\r\n"},
+		{"DoubleExponent", ",1e3\r\n"},
+		{"DoubleCapitalInf", ",Inf\r\n"},
+		{"EmptyAttribute", "|0\r\n+OK\r\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := NewRespIo(strings.NewReader(tc.input))
+
+			result, err := reader.Read()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if got := string(result.Marshal()); got != tc.input {
+				t.Errorf("Expected round trip to return %q, got %q", tc.input, got)
+			}
+		})
+	}
+}
+
+func TestRespIo_readDouble(t *testing.T) {
+	input := "1.23\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	result, err := reader.readDouble()
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if result.Typ != "double" {
+		t.Errorf("Expected type to be 'double', got %s", result.Typ)
+	}
+
+	if result.Double != 1.23 {
+		t.Errorf("Expected value 1.23, got %v", result.Double)
+	}
+}
+
+func TestRespIo_readDouble_Invalid(t *testing.T) {
+	input := "abc\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	_, err := reader.readDouble()
+
+	if err == nil {
+		t.Error("Expected an error for non-numeric double, but got nil")
+	}
+}
+
+func TestRespIo_readBoolean_Invalid(t *testing.T) {
+	input := "x\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	_, err := reader.readBoolean()
+
+	if err == nil {
+		t.Error("Expected an error for invalid boolean, but got nil")
+	}
+}
+
+func TestRespIo_readBigNumber_Invalid(t *testing.T) {
+	input := "12a4\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	_, err := reader.readBigNumber()
+
+	if err == nil {
+		t.Error("Expected an error for invalid big number, but got nil")
+	}
+}
+
+func TestRespIo_readVerbatim(t *testing.T) {
+	input := "15\r\ntxt:Some string\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	result, err := reader.readVerbatim()
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if result.Typ != "verbatim" {
+		t.Errorf("Expected type to be 'verbatim', got %s", result.Typ)
+	}
+
+	if result.Format != "txt" || result.Bulk != "Some string" {
+		t.Errorf("Expected format 'txt' and text 'Some string', got '%s' and '%s'", result.Format, result.Bulk)
+	}
+}
+
+func TestRespIo_readVerbatim_MissingFormat(t *testing.T) {
+	input := "5\r\nhello\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	_, err := reader.readVerbatim()
+
+	if err == nil {
+		t.Error("Expected an error for verbatim string without format, but got nil")
+	}
+}
+
+func TestRespIo_readMap(t *testing.T) {
+	input := "2\r\n+first\r\n:1\r\n+second\r\n:2\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	result, err := reader.readMap()
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if result.Typ != "map" {
+		t.Errorf("Expected type to be 'map', got %s", result.Typ)
+	}
+
+	if len(result.Map) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(result.Map))
+	}
+
+	if result.Map[1].Key.Str != "second" || result.Map[1].Value.Num != 2 {
+		t.Errorf("Expected second entry to be second => 2, got %s => %d", result.Map[1].Key.Str, result.Map[1].Value.Num)
+	}
+}
+
+func TestRespIo_readAttribute(t *testing.T) {
+	input := "|1\r\n+ttl\r\n:3600\r\n$5\r\nhello\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	result, err := reader.Read()
+
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if result.Typ != "bulk" || result.Bulk != "hello" {
+		t.Errorf("Expected the reply after the attribute to be bulk 'hello', got type %s and value '%s'", result.Typ, result.Bulk)
+	}
+
+	if len(result.Attrs) != 1 || result.Attrs[0].Key.Str != "ttl" || result.Attrs[0].Value.Num != 3600 {
+		t.Errorf("Expected attribute ttl => 3600, got %+v", result.Attrs)
+	}
+}
+
+// Testing protocol errors
+func TestRespIo_Read_UnknownType(t *testing.T) {
+	input := "+OK\r\n?what\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	if _, err := reader.Read(); err != nil {
+		t.Fatalf("Expected no error for the first value, got %v", err)
+	}
+
+	_, err := reader.Read()
+
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("Expected a ProtocolError wrapping ErrUnknownType, got %v", err)
+	}
+
+	if protocolErr.Offset != 5 || protocolErr.Got != "?" {
+		t.Errorf("Expected offset 5 and got '?', got offset %d and got '%s'", protocolErr.Offset, protocolErr.Got)
+	}
+}
+
+func TestRespIo_Read_CleanEOF(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("+OK\r\n"))
+
+	if _, err := reader.Read(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err := reader.Read()
+	if err != io.EOF {
+		t.Errorf("Expected io.EOF between values, got %v", err)
+	}
+}
+
+func TestRespIo_Read_TruncatedNestedValue(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("*2\r\n$3\r\nfoo\r\n"))
+
+	_, err := reader.Read()
+
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected a ProtocolError wrapping io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	if protocolErr.Offset != 13 {
+		t.Errorf("Expected offset 13, got %d", protocolErr.Offset)
+	}
+}
+
+func TestRespIo_Read_ErrorPrefix(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("*1\r\n:12x\r\n"))
+
+	_, err := reader.Read()
+
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) {
+		t.Fatalf("Expected a ProtocolError, got %v", err)
+	}
+
+	if protocolErr.Prefix != INTEGER || protocolErr.Offset != 5 || protocolErr.Got != "12x" {
+		t.Errorf("Expected prefix ':' at offset 5 with got '12x', got prefix '%c' at offset %d with got '%s'", protocolErr.Prefix, protocolErr.Offset, protocolErr.Got)
+	}
+}
+
+func TestRespIo_readNull_NotEmpty(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("x\r\n"))
+
+	_, err := reader.readNull()
+
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected ErrInvalidValue, got %v", err)
+	}
+}
+
+func TestRespIo_readLine_MissingLF(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("OK\rX"))
+
+	_, err := reader.readString()
+
+	if !errors.Is(err, ErrMissingCRLF) {
+		t.Errorf("Expected ErrMissingCRLF, got %v", err)
+	}
+}
+
+// Testing reader limits
+func TestRespIo_Limits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     ReaderOptions
+		input    string
+		expected error
+	}{
+		{"HugeBulkHeader", ReaderOptions{MaxBulkLen: 1024}, "$9999999999\r\n", ErrBulkTooLarge},
+		{"HugeBlobError", ReaderOptions{MaxBulkLen: 4}, "!5\r\nERROR\r\n", ErrBulkTooLarge},
+		{"HugeArrayHeader", ReaderOptions{MaxArrayLen: 1000}, "*2000000000\r\n", ErrArrayTooLong},
+		{"HugeMapHeader", ReaderOptions{MaxArrayLen: 1}, "%2\r\n+a\r\n+b\r\n+c\r\n+d\r\n", ErrArrayTooLong},
+		{"TooDeep", ReaderOptions{MaxDepth: 2}, "*1\r\n*1\r\n*1\r\n+deep\r\n", ErrTooDeep},
+		{"LongLine", ReaderOptions{MaxInlineLen: 4}, "+hello\r\n", ErrLineTooLong},
+		{"LongLengthLine", ReaderOptions{MaxInlineLen: 4}, "$0000000005\r\nhello\r\n", ErrLineTooLong},
+		{"LargeValue", ReaderOptions{MaxValueBytes: 16}, "*3\r\n$3\r\nfoo\r\n$3\r\nbar\r\n", ErrValueTooLarge},
+		{"LargeValueBulk", ReaderOptions{MaxValueBytes: 16}, "$100\r\n", ErrValueTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := NewRespIoWithOptions(strings.NewReader(tc.input), tc.opts)
+
+			_, err := reader.Read()
+
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Expected error to wrap ErrLimitExceeded, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRespIo_Limits_WithinLimits(t *testing.T) {
+	opts := ReaderOptions{MaxBulkLen: 3, MaxArrayLen: 2, MaxDepth: 2, MaxInlineLen: 3, MaxValueBytes: 31}
+	input := "*2\r\n*1\r\n$3\r\nfoo\r\n+bar\r\n*1\r\n:1\r\n"
+	reader := NewRespIoWithOptions(strings.NewReader(input), opts)
+
+	// the limit on the value size applies to each top level value on its own
+	for i := 0; i < 2; i++ {
+		if _, err := reader.Read(); err != nil {
+			t.Fatalf("Read %d: expected no error, got %v", i, err)
+		}
+	}
+}
+
+func TestRespIo_Limits_DefaultMaxBulkLen(t *testing.T) {
+	input := fmt.Sprintf("$%d\r\n", DefaultMaxBulkLen+1)
+	reader := NewRespIo(strings.NewReader(input))
+
+	_, err := reader.Read()
+
+	if !errors.Is(err, ErrBulkTooLarge) {
+		t.Errorf("Expected ErrBulkTooLarge, got %v", err)
+	}
+}
+
+// marshals v like AppendMarshal but spells integers, doubles and nulls in the other ways the reader accepts
+func appendRandomWire(rnd *rand.Rand, dst []byte, v Value) []byte {
+	pick := func(spellings ...string) string {
+		return spellings[rnd.IntN(len(spellings))]
+	}
+
+	if v.Attrs != nil {
+		dst = appendHeader(dst, ATTRIBUTE, len(v.Attrs))
+		for _, entry := range v.Attrs {
+			dst = appendRandomWire(rnd, dst, entry.Key)
+			dst = appendRandomWire(rnd, dst, entry.Value)
+		}
+	}
+
+	switch v.Typ {
+	case KindInt:
+		text := strconv.FormatInt(v.Num, 10)
+		if v.Num >= 0 {
+			text = pick(text, " "+text, text+" ", "+"+text, "00"+text)
+		} else {
+			text = pick(text, " "+text, "-0"+text[1:])
+		}
+		return appendLine(dst, INTEGER, text)
+	case KindDouble:
+		var text string
+		switch {
+		case math.IsNaN(v.Double):
+			text = pick("nan", "NaN")
+		case math.IsInf(v.Double, 1):
+			text = pick("inf", "+inf", "Inf", "infinity")
+		case math.IsInf(v.Double, -1):
+			text = pick("-inf", "-Inf")
+		default:
+			text = pick(strconv.FormatFloat(v.Double, 'g', -1, 64), strconv.FormatFloat(v.Double, 'e', 3, 64), strconv.FormatFloat(v.Double, 'f', -1, 64))
+		}
+		return appendLine(dst, DOUBLE, text)
+	case KindNull:
+		if rnd.IntN(2) == 0 {
+			return appendLine(dst, NULL, "")
+		}
+		return AppendNull(dst)
+	case KindArray, KindSet, KindPush:
+		prefix := map[Kind]byte{KindArray: ARRAY, KindSet: SET, KindPush: PUSH}[v.Typ]
+		dst = appendHeader(dst, prefix, len(v.Array))
+		for _, item := range v.Array {
+			dst = appendRandomWire(rnd, dst, item)
+		}
+		return dst
+	case KindMap:
+		dst = appendHeader(dst, MAP, len(v.Map))
+		for _, entry := range v.Map {
+			dst = appendRandomWire(rnd, dst, entry.Key)
+			dst = appendRandomWire(rnd, dst, entry.Value)
+		}
+		return dst
+	default:
+		v.Attrs = nil
+		return v.AppendMarshal(dst)
+	}
+}
+
+func TestRespIo_Read_RandomWireRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(3, 4))
+
+	for i := 0; i < 2000; i++ {
+		wire := appendRandomWire(rnd, nil, randomValue(rnd, 3))
+
+		for _, reader := range []interface {
+			RespReader
+			Offset() int64
+		}{NewRespIo(bytes.NewReader(wire)), NewViewReader(bytes.NewReader(wire))} {
+			result, err := reader.Read()
+			if err != nil {
+				t.Fatalf("Read() of %q returned error %v", wire, err)
+			}
+			if reader.Offset() != int64(len(wire)) {
+				t.Fatalf("Read() of %q consumed %d bytes, want %d", wire, reader.Offset(), len(wire))
+			}
+			if got := result.Marshal(); !bytes.Equal(got, wire) {
+				t.Fatalf("Marshal() after Read() = %q, want %q", got, wire)
+			}
+		}
+	}
+}
+
+func TestRespIo_Read_RejectsNonCanonicalLengths(t *testing.T) {
+	for _, input := range []string{"$+3\r\nabc\r\n", "$03\r\nabc\r\n", "* 1\r\n:1\r\n", "*-01\r\n", "%-0\r\n"} {
+		_, err := NewRespIo(strings.NewReader(input)).Read()
+		if !errors.Is(err, ErrInvalidLength) {
+			t.Errorf("Expected ErrInvalidLength for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestRespIo_readAttribute_Chained(t *testing.T) {
+	input := "|1\r\n+a\r\n:1\r\n|1\r\n+b\r\n:2\r\n+OK\r\n"
+
+	_, err := NewRespIo(strings.NewReader(input)).Read()
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected ErrInvalidValue for chained attributes, got %v", err)
+	}
+}
+
+// the seed corpus of the reader fuzz targets, valid values of every type and the malformed inputs of the tests above
+var readerFuzzSeeds = []string{
+	"+OK\r\n", "-ERR x\r\n", ":+5\r\n", ": 7\r\n", "$3\r\nabc\r\n", "$-1\r\n", "*-1\r\n", "_\r\n",
+	",1.0\r\n", ",-Inf\r\n", "#t\r\n", "(123\r\n", "=7\r\ntxt:abc\r\n", "!3\r\nERR\r\n",
+	"*2\r\n$1\r\na\r\n*1\r\n:1\r\n", "%1\r\n+k\r\n_\r\n", "~1\r\n#f\r\n", ">1\r\n+msg\r\n",
+	"|0\r\n+OK\r\n", "|1\r\n+a\r\n,0.5\r\n*0\r\n",
+	"*2\r\n*3\r\n:1\r\n:2\r\n:3\r\n*2\r\n+Hello\r\n-World\r\n", "+OK\r\n:1\r\n$-1\r\n",
+	"$5\r\nhel", "$3\r\nabcd\r\n", "*3\r\n:1\r\n:2\r\n", "$-2\r\n", ":abc\r\n", "+OK\n", "+OK\rX",
+	"#x\r\n", ",abc\r\n", "(12a\r\n", "=3\r\ntxt\r\n", "_x\r\n", "?\r\n", "",
+	"SET a \"b c\"\r\n", "\r\nPING\n", "GET 'unbalanced\r\n",
+	"$536870912\r\nabc", "*2\r\n=536870000\r\ntxt:", "%1\r\n$100000000\r\n",
+}
+
+func FuzzRespIo_ReadRoundTrip(f *testing.F) {
+	for _, seed := range readerFuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	opts := ReaderOptions{MaxBulkLen: 1 << 16, MaxArrayLen: 1 << 10, MaxDepth: 32}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, reader := range []interface {
+			RespReader
+			Offset() int64
+		}{NewRespIoWithOptions(bytes.NewReader(data), opts), NewViewReaderWithOptions(bytes.NewReader(data), opts)} {
+			result, err := reader.Read()
+			if err != nil {
+				continue
+			}
+			if got, want := result.Marshal(), data[:reader.Offset()]; !bytes.Equal(got, want) {
+				t.Errorf("Marshal() after Read() = %q, want %q", got, want)
+			}
+		}
+	})
+}
+
+func FuzzRespIo_Read(f *testing.F) {
+	for _, seed := range readerFuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	inline := DefaultReaderOptions()
+	inline.Inline = true
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, opts := range []ReaderOptions{DefaultReaderOptions(), inline} {
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+
+			reader := NewRespIoWithOptions(bytes.NewReader(data), opts)
+			var offset int64
+			for {
+				_, err := reader.Read()
+				if reader.Offset() < offset || reader.Offset() > int64(len(data)) {
+					t.Fatalf("Offset() = %d after %d, the input has %d bytes", reader.Offset(), offset, len(data))
+				}
+				offset = reader.Offset()
+				if err != nil {
+					if err != io.EOF && !errors.As(err, new(*ProtocolError)) {
+						t.Fatalf("Read() returned %v, want io.EOF or a ProtocolError", err)
+					}
+					break
+				}
+			}
+
+			// the memory must follow the bytes actually received and not the lengths the input announces
+			runtime.ReadMemStats(&after)
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20+512*uint64(len(data)) {
+				t.Fatalf("Read() of %d bytes allocated %d bytes", len(data), allocated)
+			}
+		}
+	})
+}
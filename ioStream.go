@@ -0,0 +1,121 @@
+package goresp
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrStreamClosed = errors.New("goresp: read on closed bulk stream")
+
+// BulkReader streams the payload of a bulk string straight from the connection, it is returned by RespIo.ReadStream
+// it must be closed before the next value is read, Close skips what is left of the payload and consumes the trailing CRLF
+type BulkReader struct {
+	r         *RespIo
+	size      int64
+	remaining int64
+	closed    bool
+}
+
+// Size returns the length of the whole payload as announced by the bulk header
+func (b *BulkReader) Size() int64 {
+	return b.size
+}
+
+func (b *BulkReader) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrStreamClosed
+	}
+	if b.remaining == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.r.reader.Read(p)
+	b.remaining -= int64(n)
+	b.r.offset += int64(n)
+	if err == io.EOF && b.remaining > 0 {
+		return n, b.r.ioError(err)
+	}
+
+	return n, err
+}
+
+// Close discards the unread part of the payload and the trailing CRLF, so the next value can be read
+func (b *BulkReader) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.r.stream = nil
+
+	for b.remaining > 0 {
+		chunk := b.remaining
+		if chunk > maxDiscard {
+			chunk = maxDiscard
+		}
+		n, err := b.r.reader.Discard(int(chunk))
+		b.remaining -= int64(n)
+		b.r.offset += int64(n)
+		if err != nil {
+			return b.r.ioError(err)
+		}
+	}
+
+	return b.r.readCRLF()
+}
+
+// the payload is skipped in chunks so the int conversion can't overflow on 32 bits platforms
+const maxDiscard = 1 << 30
+
+// ReadStream reads the next value like Read, but when it is a bulk string longer than threshold bytes
+// the payload is left on the connection and handed back as a BulkReader, the returned Value has Typ "bulk" and an empty Bulk
+// streamed payloads are not held in memory so MaxBulkLen and MaxValueBytes don't apply to them
+// only a top level bulk is streamed, bulks nested in aggregates are read in memory as usual
+func (r *RespIo) ReadStream(threshold int64) (Value, *BulkReader, error) {
+	if r.stream != nil {
+		if err := r.stream.Close(); err != nil {
+			return Value{}, nil, err
+		}
+	}
+
+	next, err := r.reader.Peek(1)
+	if err != nil {
+		return Value{}, nil, err
+	}
+	if next[0] != BULK {
+		v, err := r.Read()
+		return v, nil, err
+	}
+
+	r.reader.ReadByte()
+	r.valueStart = r.offset
+	r.offset++
+	r.prefix = BULK
+
+	v := Value{}
+	v.Typ = KindBulk
+
+	length, null, err := r.readLength(true)
+	if err != nil {
+		return v, nil, err
+	}
+	if null {
+		v.Typ = KindNull
+		return v, nil, nil
+	}
+
+	if int64(length) <= threshold {
+		bulk, err := r.readPayload(length)
+		if err != nil {
+			return v, nil, err
+		}
+		v.Bulk = r.payloadString(bulk)
+		return v, nil, nil
+	}
+
+	r.stream = &BulkReader{r: r, size: int64(length), remaining: int64(length)}
+
+	return v, r.stream, nil
+}
@@ -0,0 +1,149 @@
+package goresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRespIo_ReadStream_LargeBulk(t *testing.T) {
+	payload := strings.Repeat("0123456789", 10000)
+	input := "$100000\r\n" + payload + "\r\n+OK\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	v, stream, err := reader.ReadStream(1024)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if v.Typ != "bulk" || stream == nil {
+		t.Fatalf("Expected a streamed bulk, got %+v and stream %v", v, stream)
+	}
+
+	if stream.Size() != 100000 {
+		t.Errorf("Expected size 100000, got %d", stream.Size())
+	}
+
+	var out bytes.Buffer
+	if _, err := io.Copy(&out, stream); err != nil {
+		t.Fatalf("Expected no error copying the stream, got %v", err)
+	}
+
+	if out.String() != payload {
+		t.Errorf("Expected the streamed payload to match, got %d bytes", out.Len())
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Expected no error closing the stream, got %v", err)
+	}
+
+	next, err := reader.Read()
+	if err != nil || next.Str != "OK" {
+		t.Errorf("Expected the next value to be 'OK', got %+v (err %v)", next, err)
+	}
+}
+
+func TestRespIo_ReadStream_SmallBulk(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("$5\r\nhello\r\n"))
+
+	v, stream, err := reader.ReadStream(1024)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if stream != nil || v.Bulk != "hello" {
+		t.Errorf("Expected the bulk to be read in memory, got %+v and stream %v", v, stream)
+	}
+}
+
+func TestRespIo_ReadStream_OtherTypes(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("*1\r\n$5\r\nhello\r\n$-1\r\n"))
+
+	v, stream, err := reader.ReadStream(0)
+	if err != nil || stream != nil {
+		t.Fatalf("Expected an array without stream, got stream %v (err %v)", stream, err)
+	}
+
+	if v.Typ != "array" || v.Array[0].Bulk != "hello" {
+		t.Errorf("Expected nested bulks to be read in memory, got %+v", v)
+	}
+
+	v, stream, err = reader.ReadStream(0)
+	if err != nil || stream != nil || v.Typ != "null" {
+		t.Errorf("Expected a null bulk without stream, got %+v and stream %v (err %v)", v, stream, err)
+	}
+
+	if _, _, err := reader.ReadStream(0); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestRespIo_ReadStream_UnreadPayloadIsSkipped(t *testing.T) {
+	input := "$10\r\n0123456789\r\n:7\r\n"
+	reader := NewRespIo(strings.NewReader(input))
+
+	_, stream, err := reader.ReadStream(4)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	buf := make([]byte, 3)
+	io.ReadFull(stream, buf)
+
+	// the next Read closes the stream and skips the rest of the payload
+	next, err := reader.Read()
+	if err != nil || next.Num != 7 {
+		t.Errorf("Expected the next value to be 7, got %+v (err %v)", next, err)
+	}
+
+	if _, err := stream.Read(buf); err != ErrStreamClosed {
+		t.Errorf("Expected ErrStreamClosed reading a closed stream, got %v", err)
+	}
+
+	if reader.Offset() != int64(len(input)) {
+		t.Errorf("Expected offset %d, got %d", len(input), reader.Offset())
+	}
+}
+
+func TestRespIo_ReadStream_IgnoresMaxBulkLen(t *testing.T) {
+	reader := NewRespIoWithOptions(strings.NewReader("$10\r\n0123456789\r\n"), ReaderOptions{MaxBulkLen: 4})
+
+	_, stream, err := reader.ReadStream(4)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := io.ReadAll(stream)
+	if err != nil || string(data) != "0123456789" {
+		t.Errorf("Expected the whole payload, got %q (err %v)", data, err)
+	}
+}
+
+func TestRespIo_ReadStream_Truncated(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("$10\r\n01234"))
+
+	_, stream, err := reader.ReadStream(4)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, err = io.ReadAll(stream)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestRespIo_ReadStream_MissingCRLF(t *testing.T) {
+	reader := NewRespIo(strings.NewReader("$10\r\n0123456789XX"))
+
+	_, stream, err := reader.ReadStream(4)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := stream.Close(); !errors.Is(err, ErrMissingCRLF) {
+		t.Errorf("Expected ErrMissingCRLF, got %v", err)
+	}
+}
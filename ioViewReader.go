@@ -0,0 +1,196 @@
+package goresp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// ViewReader is a low allocation alternative to RespIo, it parses lines straight from the bufio buffer
+// and carves the strings and slices of the values it returns from buffers it reuses on every Read
+//
+// the Bulk and Str strings and the Array and Map slices of a Value are only valid until the next call to Read,
+// use Value.Clone to keep a value around
+type ViewReader struct {
+	io *RespIo
+}
+
+func NewViewReader(rd io.Reader) *ViewReader {
+	return NewViewReaderWithOptions(rd, DefaultReaderOptions())
+}
+
+// NewViewReaderWithOptions creates a ViewReader that enforces the given limits, use it for untrusted peers
+func NewViewReaderWithOptions(rd io.Reader, opts ReaderOptions) *ViewReader {
+	r := NewRespIoWithOptions(rd, opts)
+	r.arena = &viewArena{}
+	return &ViewReader{io: r}
+}
+
+// Read reads the next value, it invalidates the strings and slices of the value returned by the previous call
+func (r *ViewReader) Read() (Value, error) {
+	return r.io.Read()
+}
+
+// Offset returns the number of bytes consumed from the underlying reader so far
+func (r *ViewReader) Offset() int64 {
+	return r.io.Offset()
+}
+
+// viewArena holds the memory behind the values of a single Read, it is reset when the next top level value starts
+type viewArena struct {
+	bytes []byte // backing bytes of the strings
+	line  []byte // scratch for lines longer than the bufio buffer
+
+	values     []Value // backing array of the Array slices
+	valueStack []Value // elements of the aggregates still being read
+	entries    []MapEntry
+	entryStack []MapEntry
+}
+
+func (a *viewArena) reset() {
+	a.bytes = a.bytes[:0]
+	a.values = a.values[:0]
+	a.entries = a.entries[:0]
+	a.valueStack = a.valueStack[:0]
+	a.entryStack = a.entryStack[:0]
+}
+
+// returns n bytes from the arena, when it is full a new buffer is used and the old one is left to the views that point to it
+func (a *viewArena) alloc(n int) []byte {
+	if len(a.bytes)+n > cap(a.bytes) {
+		a.bytes = make([]byte, 0, max(2*cap(a.bytes), n, 4096))
+	}
+	start := len(a.bytes)
+	a.bytes = a.bytes[:start+n]
+	return a.bytes[start : start+n : start+n]
+}
+
+// returns a buffer for a payload of length bytes
+func (r *RespIo) alloc(length int) []byte {
+	if r.arena != nil {
+		return r.arena.alloc(length)
+	}
+	return make([]byte, length)
+}
+
+// converts a payload returned by alloc to a string, in view mode it aliases the arena instead of copying
+func (r *RespIo) payloadString(b []byte) string {
+	if r.arena != nil {
+		return unsafe.String(unsafe.SliceData(b), len(b))
+	}
+	return string(b)
+}
+
+// converts a line returned by readLine to a string, in view mode the line still lives in the bufio buffer so it is copied to the arena
+func (r *RespIo) lineString(line []byte) string {
+	if r.arena != nil {
+		b := r.arena.alloc(len(line))
+		copy(b, line)
+		return r.payloadString(b)
+	}
+	return string(line)
+}
+
+// readLine of the view mode, the returned line points to the bufio buffer and is only valid until the next read
+func (r *RespIo) readLineSlice() (line []byte, numBytes int, err error) {
+	start := r.offset
+	line, err = r.reader.ReadSlice('\n')
+	// the line doesn't fit the bufio buffer so it is gathered in the scratch buffer
+	if err == bufio.ErrBufferFull {
+		scratch := append(r.arena.line[:0], line...)
+		for err == bufio.ErrBufferFull {
+			r.offset = start + int64(len(scratch))
+			if err := r.checkLineSize(start, len(scratch)); err != nil {
+				return nil, len(scratch), err
+			}
+			line, err = r.reader.ReadSlice('\n')
+			scratch = append(scratch, line...)
+		}
+		r.arena.line = scratch
+		line = scratch
+	}
+	numBytes = len(line)
+	r.offset = start + int64(numBytes)
+
+	if err := r.checkLineSize(start, numBytes); err != nil {
+		return nil, numBytes, err
+	}
+	if err != nil {
+		return nil, numBytes, r.ioError(err)
+	}
+
+	// a '\r' must only appear right before the '\n', like the byte by byte readLine
+	if numBytes < 2 || bytes.IndexByte(line[:numBytes-1], '\r') != numBytes-2 {
+		return nil, numBytes, r.protocolError(start, ErrMissingCRLF, "CRLF at the end of the line", line)
+	}
+
+	return line[:numBytes-2], numBytes, nil
+}
+
+// applies MaxInlineLen and MaxValueBytes to a line of n bytes read so far
+func (r *RespIo) checkLineSize(start int64, n int) error {
+	if max := r.opts.MaxInlineLen; max > 0 && n > max+2 {
+		return r.protocolError(start, ErrLineTooLong, fmt.Sprintf("a line of at most %d bytes", max), nil)
+	}
+	return r.checkValueSize(0)
+}
+
+// reads the elements of an aggregate on a stack and moves them to the arena once they are all read,
+// that keeps the elements contiguous even though nested aggregates are read in between
+func (r *RespIo) readValuesView(length int) ([]Value, error) {
+	a := r.arena
+	base := len(a.valueStack)
+
+	var err error
+	for i := 0; i < length; i++ {
+		var val Value
+		val, err = r.Read()
+		if err != nil {
+			break
+		}
+		a.valueStack = append(a.valueStack, val)
+	}
+
+	// empty aggregates are read as empty slices and not nil, like RespIo does
+	if len(a.valueStack) == base {
+		return []Value{}, err
+	}
+
+	start := len(a.values)
+	a.values = append(a.values, a.valueStack[base:]...)
+	a.valueStack = a.valueStack[:base]
+
+	return a.values[start:len(a.values):len(a.values)], err
+}
+
+// same as readValuesView for the pairs of a map or an attribute
+func (r *RespIo) readPairsView(length int) ([]MapEntry, error) {
+	a := r.arena
+	base := len(a.entryStack)
+
+	var err error
+	for i := 0; i < length; i++ {
+		var key, val Value
+		key, err = r.Read()
+		if err != nil {
+			break
+		}
+		val, err = r.Read()
+		if err != nil {
+			break
+		}
+		a.entryStack = append(a.entryStack, MapEntry{Key: key, Value: val})
+	}
+
+	if len(a.entryStack) == base {
+		return []MapEntry{}, err
+	}
+
+	start := len(a.entries)
+	a.entries = append(a.entries, a.entryStack[base:]...)
+	a.entryStack = a.entryStack[:base]
+
+	return a.entries[start:len(a.entries):len(a.entries)], err
+}
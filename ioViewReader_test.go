@@ -0,0 +1,211 @@
+package goresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var viewReaderInputs = []string{
+	"+OK\r\n",
+	"-ERR unknown command\r\n",
+	":42\r\n",
+	"$5\r\nhello\r\n",
+	"$0\r\n\r\n",
+	"$-1\r\n",
+	"*-1\r\n",
+	"*0\r\n",
+	"_\r\n",
+	"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n",
+	"*2\r\n*3\r\n:1\r\n:2\r\n:3\r\n*2\r\n+Hello\r\n-World\r\n",
+	",3.14\r\n",
+	"#t\r\n",
+	"(12345678901234567890\r\n",
+	"=15\r\ntxt:Some string\r\n",
+	"!21\r\nSYNTAX invalid syntax\r\n",
+	"%2\r\n+first\r\n*1\r\n:1\r\n+second\r\n%1\r\n+a\r\n+b\r\n",
+	"~2\r\n+orange\r\n+apple\r\n",
+	">2\r\n$7\r\nmessage\r\n*1\r\n$5\r\nhello\r\n",
+	"|1\r\n+ttl\r\n:3600\r\n$5\r\nhello\r\n",
+	"+" + strings.Repeat("a", 10000) + "\r\n",
+}
+
+func TestViewReader_MatchesRespIo(t *testing.T) {
+	for _, input := range viewReaderInputs {
+		expected, expectedErr := NewRespIo(strings.NewReader(input)).Read()
+		result, err := NewViewReader(strings.NewReader(input)).Read()
+
+		if err != expectedErr {
+			t.Errorf("Input %.20q: expected error %v, got %v", input, expectedErr, err)
+		}
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Input %.20q: expected %+v, got %+v", input, expected, result)
+		}
+	}
+}
+
+func TestViewReader_ConsecutiveReads(t *testing.T) {
+	input := strings.Join(viewReaderInputs, "")
+	expectedReader := NewRespIo(strings.NewReader(input))
+	reader := NewViewReader(strings.NewReader(input))
+
+	for i := range viewReaderInputs {
+		expected, _ := expectedReader.Read()
+		result, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Read %d: expected no error, got %v", i, err)
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Read %d: expected %+v, got %+v", i, expected, result)
+		}
+	}
+
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end of the stream, got %v", err)
+	}
+
+	if reader.Offset() != int64(len(input)) {
+		t.Errorf("Expected offset %d, got %d", len(input), reader.Offset())
+	}
+}
+
+func TestViewReader_ValuesValidUntilNextRead(t *testing.T) {
+	input := "$5\r\nfirst\r\n$5\r\nlater\r\n"
+	reader := NewViewReader(strings.NewReader(input))
+
+	first, _ := reader.Read()
+	kept := first.Clone()
+
+	if _, err := reader.Read(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the arena is reused so the view now shows the second payload
+	if first.Bulk != "later" {
+		t.Errorf("Expected the view to be overwritten by the next read, got '%s'", first.Bulk)
+	}
+
+	if kept.Bulk != "first" {
+		t.Errorf("Expected the clone to keep 'first', got '%s'", kept.Bulk)
+	}
+}
+
+func TestViewReader_Errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     ReaderOptions
+		input    string
+		expected error
+	}{
+		{"Truncated", DefaultReaderOptions(), "*2\r\n$3\r\nfoo\r\n", io.ErrUnexpectedEOF},
+		{"TruncatedLine", DefaultReaderOptions(), "+OK", io.ErrUnexpectedEOF},
+		{"MissingLF", DefaultReaderOptions(), "+a\rb\r\n", ErrMissingCRLF},
+		{"UnknownType", DefaultReaderOptions(), "?\r\n", ErrUnknownType},
+		{"HugeBulk", ReaderOptions{MaxBulkLen: 10}, "$11\r\n", ErrBulkTooLarge},
+		{"LongLine", ReaderOptions{MaxInlineLen: 4}, "+hello\r\n", ErrLineTooLong},
+		{"LongLineOverBuffer", ReaderOptions{MaxInlineLen: 5000}, "+" + strings.Repeat("a", 10000) + "\r\n", ErrLineTooLong},
+		{"TooDeep", ReaderOptions{MaxDepth: 1}, "*1\r\n*1\r\n+deep\r\n", ErrTooDeep},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewViewReaderWithOptions(strings.NewReader(tc.input), tc.opts).Read()
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestViewReader_NoAllocations(t *testing.T) {
+	command := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n*2\r\n+OK\r\n:12\r\n"
+	source := &loopReader{data: []byte(command)}
+	reader := NewViewReader(source)
+
+	// warm up the arena
+	for i := 0; i < 10; i++ {
+		reader.Read()
+	}
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		if _, err := reader.Read(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Errorf("Expected no allocations per read, got %v", allocs)
+	}
+}
+
+// loopReader endlessly repeats data, it feeds the readers in the benchmarks
+type loopReader struct {
+	data []byte
+	pos  int
+}
+
+func (l *loopReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], l.data[l.pos:])
+		n += c
+		l.pos = (l.pos + c) % len(l.data)
+	}
+	return n, nil
+}
+
+var benchmarkCommand = []byte("*3\r\n$3\r\nSET\r\n$10\r\nuser:12345\r\n$64\r\n" + strings.Repeat("v", 64) + "\r\n")
+
+func BenchmarkRespIo_Read(b *testing.B) {
+	reader := NewRespIo(&loopReader{data: benchmarkCommand})
+	b.SetBytes(int64(len(benchmarkCommand)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := reader.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewReader_Read(b *testing.B) {
+	reader := NewViewReader(&loopReader{data: benchmarkCommand})
+	b.SetBytes(int64(len(benchmarkCommand)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := reader.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRespIo_ReadLargeBulk(b *testing.B) {
+	data := []byte("$65536\r\n" + string(bytes.Repeat([]byte("x"), 65536)) + "\r\n")
+	reader := NewRespIo(&loopReader{data: data})
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := reader.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewReader_ReadLargeBulk(b *testing.B) {
+	data := []byte("$65536\r\n" + string(bytes.Repeat([]byte("x"), 65536)) + "\r\n")
+	reader := NewViewReader(&loopReader{data: data})
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := reader.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
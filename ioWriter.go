@@ -1,10 +1,18 @@
 package goresp
 
-import "io"
+import (
+	"bufio"
+	"io"
+	"sync"
+)
 
-// Writer
+// Writer encodes each value and sends it in a single Write call of the underlying writer
+// it is safe for concurrent use, the values of different goroutines never interleave
 type Writer struct {
 	writer io.Writer
+
+	mu  sync.Mutex
+	buf []byte // reused between writes so encoding doesn't allocate, guarded by mu
 }
 
 func NewWriter(w io.Writer) *Writer {
@@ -13,12 +21,113 @@ func NewWriter(w io.Writer) *Writer {
 
 // it converts the Value to a resp and writes it to the io
 func (w *Writer) Write(v Value) error {
-	var bytes = v.Marshal()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = v.AppendMarshal(w.buf[:0])
 
-	_, err := w.writer.Write(bytes)
+	_, err := w.writer.Write(w.buf)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// FlushPolicy decides when a BufferedWriter sends its buffer without an explicit Flush
+type FlushPolicy int
+
+const (
+	// FlushManual only writes when Flush is called or when the buffer is full
+	FlushManual FlushPolicy = iota
+	// FlushAlways flushes at the end of every Write and WriteMany call
+	FlushAlways
+	// FlushPending flushes once MaxPending values are waiting in the buffer
+	FlushPending
+)
+
+type WriterOptions struct {
+	BufferSize int         // the size of the buffer, 0 uses the bufio default of 4096 bytes
+	Flush      FlushPolicy // when to flush without an explicit call to Flush
+	MaxPending int         // how many values FlushPending lets wait in the buffer
+}
+
+// BufferedWriter gathers the values in a bufio.Writer so a pipeline of commands goes out in a few write calls
+// it satisfies RespWriter, remember to call Flush unless the policy is FlushAlways
+// unlike Writer it is not safe for concurrent use
+type BufferedWriter struct {
+	writer  *bufio.Writer
+	opts    WriterOptions
+	pending int // values written since the last flush
+}
+
+func NewBufferedWriter(w io.Writer) *BufferedWriter {
+	return NewBufferedWriterWithOptions(w, WriterOptions{})
+}
+
+func NewBufferedWriterWithOptions(w io.Writer, opts WriterOptions) *BufferedWriter {
+	size := opts.BufferSize
+	if size <= 0 {
+		size = 4096
+	}
+	return &BufferedWriter{writer: bufio.NewWriterSize(w, size), opts: opts}
+}
+
+// Write buffers the value and flushes if the policy asks for it
+func (w *BufferedWriter) Write(v Value) error {
+	if err := w.write(v); err != nil {
+		return err
+	}
+
+	return w.autoFlush()
+}
+
+// WriteMany buffers all the values as one batch, the policy is only applied once they are all buffered
+func (w *BufferedWriter) WriteMany(values ...Value) error {
+	for _, v := range values {
+		if err := w.write(v); err != nil {
+			return err
+		}
+	}
+
+	return w.autoFlush()
+}
+
+// Flush writes the buffered values to the underlying writer
+func (w *BufferedWriter) Flush() error {
+	w.pending = 0
+	return w.writer.Flush()
+}
+
+// Buffered returns the number of bytes waiting in the buffer
+func (w *BufferedWriter) Buffered() int {
+	return w.writer.Buffered()
+}
+
+// Pending returns the number of values written since the last flush
+func (w *BufferedWriter) Pending() int {
+	return w.pending
+}
+
+func (w *BufferedWriter) write(v Value) error {
+	// encoding straight into the free space of the buffer avoids a copy and an allocation
+	if _, err := w.writer.Write(v.AppendMarshal(w.writer.AvailableBuffer())); err != nil {
+		return err
+	}
+	w.pending++
+
+	return nil
+}
+
+func (w *BufferedWriter) autoFlush() error {
+	switch w.opts.Flush {
+	case FlushAlways:
+		return w.Flush()
+	case FlushPending:
+		if w.pending >= w.opts.MaxPending {
+			return w.Flush()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package goresp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingWriter records how many write calls reach the underlying writer
+type countingWriter struct {
+	bytes.Buffer
+	calls int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.calls++
+	return c.Buffer.Write(p)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriter_Write(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+
+	if err := w.Write(NewSetValue("key", "value")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "*3\r\n$3\r\nset\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestWriter_Write_Concurrent(t *testing.T) {
+	var out lockedBuffer
+	w := NewWriter(&out)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v := NewSetValue("key", strings.Repeat(string(rune('a'+i)), 100+i))
+			for range 100 {
+				w.Write(v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reader := NewRespIo(strings.NewReader(out.String()))
+	for i := range 800 {
+		v, err := reader.Read()
+		if err != nil || len(v.Array) != 3 || strings.Trim(v.Array[2].Bulk, v.Array[2].Bulk[:1]) != "" {
+			t.Fatalf("value %d = %+v, %v, want an intact SET", i, v, err)
+		}
+	}
+}
+
+func TestBufferedWriter_ManualFlush(t *testing.T) {
+	out := &countingWriter{}
+	w := NewBufferedWriter(out)
+
+	for i := 0; i < 1000; i++ {
+		if err := w.Write(NewNumberValue(int64(i))); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if w.Pending() != 1000 {
+		t.Errorf("Expected 1000 pending values, got %d", w.Pending())
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// 1000 small integers are a few kilobytes, far less than one write per value
+	if out.calls > 3 {
+		t.Errorf("Expected at most 3 write calls, got %d", out.calls)
+	}
+
+	if w.Buffered() != 0 || w.Pending() != 0 {
+		t.Errorf("Expected an empty buffer after Flush, got %d bytes and %d values", w.Buffered(), w.Pending())
+	}
+
+	reader := NewRespIo(&out.Buffer)
+	for i := 0; i < 1000; i++ {
+		v, err := reader.Read()
+		if err != nil || v.Num != int64(i) {
+			t.Fatalf("Value %d: expected %d, got %+v (err %v)", i, i, v, err)
+		}
+	}
+}
+
+func TestBufferedWriter_WriteManyFlushAlways(t *testing.T) {
+	out := &countingWriter{}
+	w := NewBufferedWriterWithOptions(out, WriterOptions{Flush: FlushAlways})
+
+	err := w.WriteMany(NewSetValue("a", "1"), NewSetValue("b", "2"), NewDelValue([]string{"a"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.calls != 1 {
+		t.Errorf("Expected the batch to be sent in 1 write call, got %d", out.calls)
+	}
+
+	expected := string(NewSetValue("a", "1").Marshal()) + string(NewSetValue("b", "2").Marshal()) + string(NewDelValue([]string{"a"}).Marshal())
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestBufferedWriter_FlushPending(t *testing.T) {
+	out := &countingWriter{}
+	w := NewBufferedWriterWithOptions(out, WriterOptions{Flush: FlushPending, MaxPending: 3})
+
+	w.Write(NewNumberValue(1))
+	w.Write(NewNumberValue(2))
+	if out.calls != 0 {
+		t.Errorf("Expected nothing written before 3 values, got %d calls", out.calls)
+	}
+
+	w.Write(NewNumberValue(3))
+	if out.calls != 1 || out.String() != ":1\r\n:2\r\n:3\r\n" {
+		t.Errorf("Expected the 3 values in 1 write call, got %q in %d calls", out.String(), out.calls)
+	}
+}
+
+func TestBufferedWriter_FlushError(t *testing.T) {
+	w := NewBufferedWriterWithOptions(failingWriter{}, WriterOptions{Flush: FlushAlways})
+
+	if err := w.Write(NewNumberValue(1)); err == nil {
+		t.Error("Expected the error of the underlying writer, got nil")
+	}
+}
+
+func TestBufferedWriter_IsRespWriter(t *testing.T) {
+	var _ RespWriter = NewBufferedWriter(&bytes.Buffer{})
+	var _ RespWriter = NewWriter(&bytes.Buffer{})
+}
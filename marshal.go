@@ -0,0 +1,631 @@
+package goresp
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RespMarshaler is implemented by types that build their own Value in Marshal
+type RespMarshaler interface {
+	MarshalRESP() (Value, error)
+}
+
+// RespUnmarshaler is implemented by types that decode themselves in Unmarshal
+type RespUnmarshaler interface {
+	UnmarshalRESP(Value) error
+}
+
+// UnsupportedTypeError is returned by Marshal for Go types that have no RESP representation like channels and funcs
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "goresp: unsupported type: " + e.Type.String()
+}
+
+// InvalidUnmarshalError is returned by Unmarshal when the target is not a non nil pointer
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "goresp: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Pointer {
+		return "goresp: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "goresp: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// UnmarshalTypeError is returned by Unmarshal when a value can't be stored in the Go type it is decoded into
+type UnmarshalTypeError struct {
+	Value Kind         // the Typ of the RESP value
+	Type  reflect.Type // the Go type it could not be assigned to
+	Field string       // the path of the struct field or map key, empty at the top level
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Field != "" {
+		return "goresp: cannot unmarshal " + string(e.Value) + " into Go struct field " + e.Field + " of type " + e.Type.String()
+	}
+	return "goresp: cannot unmarshal " + string(e.Value) + " into Go value of type " + e.Type.String()
+}
+
+// Marshal converts a Go value to a Value the way encoding/json converts to JSON:
+//   - string, []byte and encoding.TextMarshaler become bulk strings
+//   - integers become "int", floats "double" and bools "boolean"
+//   - slices and arrays become arrays, a nil slice the null array
+//   - maps become maps with their keys sorted, structs become maps keyed by field name
+//   - nil pointers and interfaces become the null bulk string
+//
+// struct fields are renamed with a `resp:"name"` tag, `resp:"-"` skips a field and `resp:",omitempty"` skips zero values
+func Marshal(v any) (Value, error) {
+	return marshalReflect(reflect.ValueOf(v))
+}
+
+var (
+	valueType     = reflect.TypeOf(Value{})
+	marshalerType = reflect.TypeOf((*RespMarshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func marshalReflect(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return NewNullValue(), nil
+	}
+
+	if rv.Type() == valueType {
+		return rv.Interface().(Value), nil
+	}
+	// a nil interface has no method to call, even when its type lists the marshaler ones
+	if (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil() {
+		return NewNullValue(), nil
+	}
+	if rv.Type().Implements(marshalerType) {
+		return rv.Interface().(RespMarshaler).MarshalRESP()
+	}
+	if rv.Type().Implements(textMarshaler) {
+		text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{Typ: KindBulk, Bulk: string(text)}, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return marshalReflect(rv.Elem())
+	case reflect.String:
+		return Value{Typ: KindBulk, Bulk: rv.String()}, nil
+	case reflect.Bool:
+		return NewBooleanValue(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewNumberValue(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := rv.Uint()
+		if n > math.MaxInt64 {
+			return NewBigNumberValue(strconv.FormatUint(n, 10)), nil
+		}
+		return NewNumberValue(int64(n)), nil
+	case reflect.Float32, reflect.Float64:
+		return NewDoubleValue(rv.Float()), nil
+	case reflect.Slice:
+		if rv.IsNil() {
+			return NewNullArrayValue(), nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return Value{Typ: KindBulk, Bulk: string(rv.Bytes())}, nil
+		}
+		return marshalArray(rv)
+	case reflect.Array:
+		return marshalArray(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			return NewNullValue(), nil
+		}
+		return marshalMap(rv)
+	case reflect.Struct:
+		return marshalStruct(rv)
+	default:
+		return Value{}, &UnsupportedTypeError{Type: rv.Type()}
+	}
+}
+
+func marshalArray(rv reflect.Value) (Value, error) {
+	items := make([]Value, rv.Len())
+	for i := range items {
+		item, err := marshalReflect(rv.Index(i))
+		if err != nil {
+			return Value{}, err
+		}
+		items[i] = item
+	}
+
+	return Value{Typ: KindArray, Array: items}, nil
+}
+
+// the entries are sorted by the encoding of their keys so the output doesn't depend on the map iteration order
+func marshalMap(rv reflect.Value) (Value, error) {
+	type sortable struct {
+		entry MapEntry
+		key   []byte
+	}
+
+	entries := make([]sortable, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := marshalReflect(iter.Key())
+		if err != nil {
+			return Value{}, err
+		}
+		val, err := marshalReflect(iter.Value())
+		if err != nil {
+			return Value{}, err
+		}
+		entries = append(entries, sortable{entry: MapEntry{Key: key, Value: val}, key: key.AppendMarshal(nil)})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	result := make([]MapEntry, len(entries))
+	for i := range entries {
+		result[i] = entries[i].entry
+	}
+
+	return NewMapValue(result), nil
+}
+
+func marshalStruct(rv reflect.Value) (Value, error) {
+	entries := make([]MapEntry, 0)
+	for _, f := range structFields(rv.Type()) {
+		field, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && field.IsZero() {
+			continue
+		}
+		val, err := marshalReflect(field)
+		if err != nil {
+			return Value{}, err
+		}
+		entries = append(entries, MapEntry{Key: Value{Typ: KindBulk, Bulk: f.name}, Value: val})
+	}
+
+	return NewMapValue(entries), nil
+}
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// lists the fields that are encoded with their resp names, the fields of embedded structs are promoted like in encoding/json
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for _, f := range reflect.VisibleFields(t) {
+		tag := f.Tag.Get("resp")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			// its fields are listed on their own by VisibleFields
+			if ft.Kind() == reflect.Struct {
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name: name, index: f.Index, omitEmpty: opts == "omitempty"})
+	}
+
+	return fields
+}
+
+// like reflect.Value.FieldByIndex but reports false instead of panicking on a nil embedded pointer
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+
+	return rv, true
+}
+
+// Unmarshal stores the content of v in the Go value pointed to by dst, the reverse of Marshal
+// it is lenient with the replies of RESP2 servers: numbers sent as bulk strings are parsed,
+// flat arrays of field value pairs like HGETALL replies fill maps and structs
+// null values set dst to its zero value and error replies are returned as *ReplyError
+func Unmarshal(v Value, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(dst)}
+	}
+
+	return unmarshalReflect(v, rv.Elem(), "")
+}
+
+func isNullValue(v Value) bool {
+	return v.Typ == KindNull || v.Typ == KindNullArray
+}
+
+func unmarshalReflect(v Value, rv reflect.Value, path string) error {
+	if rv.Type() == valueType {
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+	if v.IsError() {
+		return v.Err()
+	}
+	if isNullValue(v) {
+		rv.SetZero()
+		return nil
+	}
+
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshalReflect(v, rv.Elem(), path)
+	}
+	if rv.CanAddr() {
+		if u, ok := rv.Addr().Interface().(RespUnmarshaler); ok {
+			return u.UnmarshalRESP(v)
+		}
+		if u, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok && rv.Kind() != reflect.Slice {
+			text, ok := textOf(v)
+			if !ok {
+				return typeError(v, rv, path)
+			}
+			return u.UnmarshalText([]byte(text))
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return typeError(v, rv, path)
+		}
+		natural, err := naturalValue(v)
+		if err != nil {
+			return err
+		}
+		if natural == nil {
+			rv.SetZero()
+			return nil
+		}
+		rv.Set(reflect.ValueOf(natural))
+		return nil
+	case reflect.String:
+		text, ok := textOf(v)
+		if !ok {
+			return typeError(v, rv, path)
+		}
+		rv.SetString(text)
+		return nil
+	case reflect.Bool:
+		b, ok := boolOf(v)
+		if !ok {
+			return typeError(v, rv, path)
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := intOf(v)
+		if !ok || rv.OverflowInt(n) {
+			return typeError(v, rv, path)
+		}
+		rv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := uintOf(v)
+		if !ok || rv.OverflowUint(n) {
+			return typeError(v, rv, path)
+		}
+		rv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := floatOf(v)
+		if !ok || rv.OverflowFloat(f) {
+			return typeError(v, rv, path)
+		}
+		rv.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			text, ok := textOf(v)
+			if !ok {
+				return typeError(v, rv, path)
+			}
+			rv.SetBytes([]byte(text))
+			return nil
+		}
+		items, ok := itemsOf(v)
+		if !ok {
+			return typeError(v, rv, path)
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := unmarshalReflect(item, slice.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.Array:
+		items, ok := itemsOf(v)
+		if !ok {
+			return typeError(v, rv, path)
+		}
+		// like encoding/json the extra items are dropped and the missing ones zeroed
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(items) {
+				rv.Index(i).SetZero()
+				continue
+			}
+			if err := unmarshalReflect(items[i], rv.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		entries, ok := pairsOf(v)
+		if !ok {
+			return typeError(v, rv, path)
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), len(entries)))
+		}
+		for _, entry := range entries {
+			key := reflect.New(rv.Type().Key()).Elem()
+			if err := unmarshalReflect(entry.Key, key, path); err != nil {
+				return err
+			}
+			val := reflect.New(rv.Type().Elem()).Elem()
+			if err := unmarshalReflect(entry.Value, val, path+"["+fmt.Sprint(key.Interface())+"]"); err != nil {
+				return err
+			}
+			rv.SetMapIndex(key, val)
+		}
+		return nil
+	case reflect.Struct:
+		entries, ok := pairsOf(v)
+		if !ok {
+			return typeError(v, rv, path)
+		}
+		fields := structFields(rv.Type())
+		for _, entry := range entries {
+			name, ok := textOf(entry.Key)
+			if !ok {
+				continue
+			}
+			f, ok := findField(fields, name)
+			if !ok {
+				continue
+			}
+			field, ok := allocFieldByIndex(rv, f.index)
+			if !ok {
+				continue
+			}
+			fieldPath := f.name
+			if path != "" {
+				fieldPath = path + "." + f.name
+			}
+			if err := unmarshalReflect(entry.Value, field, fieldPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return typeError(v, rv, path)
+	}
+}
+
+func typeError(v Value, rv reflect.Value, path string) error {
+	return &UnmarshalTypeError{Value: v.Typ, Type: rv.Type(), Field: path}
+}
+
+// an exact name match wins over a case insensitive one, like in encoding/json
+func findField(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
+
+// like fieldByIndex but allocates the nil embedded pointers on the way
+func allocFieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+
+	return rv, true
+}
+
+// the text carried by the string like values, numbers are formatted
+func textOf(v Value) (string, bool) {
+	switch v.Typ {
+	case KindBulk, KindVerbatim:
+		return v.Bulk, true
+	case KindString, KindBigNumber:
+		return v.Str, true
+	case KindInt, KindInteger:
+		return strconv.FormatInt(v.Num, 10), true
+	case KindDouble:
+		return strconv.FormatFloat(v.Double, 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+func intOf(v Value) (int64, bool) {
+	switch v.Typ {
+	case KindInt, KindInteger:
+		return v.Num, true
+	case KindBulk, KindString, KindBigNumber:
+		text, _ := textOf(v)
+		n, err := strconv.ParseInt(text, 10, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func uintOf(v Value) (uint64, bool) {
+	switch v.Typ {
+	case KindInt, KindInteger:
+		return uint64(v.Num), v.Num >= 0
+	case KindBulk, KindString, KindBigNumber:
+		text, _ := textOf(v)
+		n, err := strconv.ParseUint(text, 10, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func floatOf(v Value) (float64, bool) {
+	switch v.Typ {
+	case KindDouble:
+		return v.Double, true
+	case KindInt, KindInteger:
+		return float64(v.Num), true
+	case KindBulk, KindString, KindBigNumber:
+		text, _ := textOf(v)
+		f, err := strconv.ParseFloat(text, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// booleans also come as the integers 1 and 0 in RESP2 replies like EXISTS or SISMEMBER
+func boolOf(v Value) (bool, bool) {
+	switch v.Typ {
+	case KindBoolean:
+		return v.Bool, true
+	case KindInt, KindInteger:
+		return v.Num != 0, v.Num == 0 || v.Num == 1
+	case KindBulk, KindString:
+		text, _ := textOf(v)
+		b, err := strconv.ParseBool(text)
+		return b, err == nil
+	default:
+		return false, false
+	}
+}
+
+func itemsOf(v Value) ([]Value, bool) {
+	switch v.Typ {
+	case KindArray, KindSet, KindPush:
+		return v.Array, true
+	default:
+		return nil, false
+	}
+}
+
+// the pairs of a map, or of a flat array of alternating keys and values
+func pairsOf(v Value) ([]MapEntry, bool) {
+	switch v.Typ {
+	case KindMap:
+		return v.Map, true
+	case KindArray:
+		if len(v.Array)%2 != 0 {
+			return nil, false
+		}
+		entries := make([]MapEntry, len(v.Array)/2)
+		for i := range entries {
+			entries[i] = MapEntry{Key: v.Array[2*i], Value: v.Array[2*i+1]}
+		}
+		return entries, true
+	default:
+		return nil, false
+	}
+}
+
+// the Go value an empty interface receives: string, int64, float64, bool, []any, map[string]any or nil
+func naturalValue(v Value) (any, error) {
+	switch v.Typ {
+	case KindNull, KindNullArray:
+		return nil, nil
+	case KindBulk, KindVerbatim:
+		return v.Bulk, nil
+	case KindString, KindBigNumber:
+		return v.Str, nil
+	case KindInt, KindInteger:
+		return v.Num, nil
+	case KindDouble:
+		return v.Double, nil
+	case KindBoolean:
+		return v.Bool, nil
+	case KindArray, KindSet, KindPush:
+		items := make([]any, len(v.Array))
+		for i, item := range v.Array {
+			natural, err := naturalValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = natural
+		}
+		return items, nil
+	case KindMap:
+		m := make(map[string]any, len(v.Map))
+		for _, entry := range v.Map {
+			key, ok := textOf(entry.Key)
+			if !ok {
+				return nil, &UnmarshalTypeError{Value: entry.Key.Typ, Type: reflect.TypeOf("")}
+			}
+			natural, err := naturalValue(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = natural
+		}
+		return m, nil
+	case KindError, KindBlobError:
+		return nil, v.Err()
+	default:
+		return nil, &UnmarshalTypeError{Value: v.Typ, Type: reflect.TypeOf((*any)(nil)).Elem()}
+	}
+}
@@ -0,0 +1,303 @@
+package goresp
+
+import (
+	"encoding"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type marshalUser struct {
+	Name    string            `resp:"name"`
+	Age     int               `resp:"age"`
+	Email   string            `resp:"email,omitempty"`
+	Tags    []string          `resp:"tags"`
+	Secret  string            `resp:"-"`
+	Score   float64           // no tag, the field name is used
+	Admin   bool              `resp:"admin"`
+	Extra   map[string]string `resp:"extra,omitempty"`
+	private string
+}
+
+type marshalBase struct {
+	ID int64 `resp:"id"`
+}
+
+type marshalEmbedded struct {
+	marshalBase
+	Title string `resp:"title"`
+}
+
+type upperString string
+
+func (u upperString) MarshalRESP() (Value, error) {
+	return Value{Typ: "string", Str: strings.ToUpper(string(u))}, nil
+}
+
+func (u *upperString) UnmarshalRESP(v Value) error {
+	*u = upperString(strings.ToLower(v.Str))
+	return nil
+}
+
+func TestMarshal_Scalars(t *testing.T) {
+	var nilPointer *int
+	testCases := []struct {
+		name     string
+		input    any
+		expected Value
+	}{
+		{"String", "hello", Value{Typ: "bulk", Bulk: "hello"}},
+		{"Bytes", []byte("raw\x00"), Value{Typ: "bulk", Bulk: "raw\x00"}},
+		{"Int", 42, NewNumberValue(42)},
+		{"Int8", int8(-8), NewNumberValue(-8)},
+		{"Uint", uint32(7), NewNumberValue(7)},
+		{"HugeUint", uint64(math.MaxUint64), NewBigNumberValue("18446744073709551615")},
+		{"Float", 1.5, NewDoubleValue(1.5)},
+		{"Bool", true, NewBooleanValue(true)},
+		{"Nil", nil, NewNullValue()},
+		{"NilPointer", nilPointer, NewNullValue()},
+		{"NilSlice", []string(nil), NewNullArrayValue()},
+		{"Value", NewErrorValue("ERR"), NewErrorValue("ERR")},
+		{"Marshaler", upperString("shout"), Value{Typ: "string", Str: "SHOUT"}},
+		{"TextMarshaler", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Value{Typ: "bulk", Bulk: "2024-01-02T03:04:05Z"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Marshal(tc.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Marshal(%v) = %+v, want %+v", tc.input, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestMarshal_Slice(t *testing.T) {
+	result, err := Marshal([]any{"SET", "key", 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n:1\r\n"
+	if string(result.Marshal()) != expected {
+		t.Errorf("Expected %q, got %q", expected, result.Marshal())
+	}
+}
+
+func TestMarshal_MapIsSorted(t *testing.T) {
+	result, err := Marshal(map[string]int{"b": 2, "c": 3, "a": 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "%3\r\n$1\r\na\r\n:1\r\n$1\r\nb\r\n:2\r\n$1\r\nc\r\n:3\r\n"
+	if string(result.Marshal()) != expected {
+		t.Errorf("Expected %q, got %q", expected, result.Marshal())
+	}
+}
+
+func TestMarshal_Struct(t *testing.T) {
+	user := marshalUser{Name: "ann", Age: 30, Tags: []string{"a"}, Secret: "x", Score: 2.5, private: "p"}
+
+	result, err := Marshal(&user)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var keys []string
+	for _, entry := range result.Map {
+		keys = append(keys, entry.Key.Bulk)
+	}
+
+	expected := []string{"name", "age", "tags", "Score", "admin"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+}
+
+func TestMarshal_NilInterfaceField(t *testing.T) {
+	value := struct {
+		M RespMarshaler          `resp:"m"`
+		T encoding.TextMarshaler `resp:"t"`
+		U RespMarshaler          `resp:"u"`
+	}{U: upperString("ok")}
+
+	result, err := Marshal(value)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Map) != 3 || !result.Map[0].Value.IsNull() || !result.Map[1].Value.IsNull() || result.Map[2].Value.Str != "OK" {
+		t.Errorf("Expected null fields and OK, got %+v", result.Map)
+	}
+}
+
+func TestMarshal_Unsupported(t *testing.T) {
+	_, err := Marshal(map[string]any{"ch": make(chan int)})
+
+	var unsupported *UnsupportedTypeError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("Expected UnsupportedTypeError, got %v", err)
+	}
+}
+
+func TestUnmarshal_RoundTrip(t *testing.T) {
+	user := marshalUser{Name: "ann", Age: 30, Email: "a@b.c", Tags: []string{"x", "y"}, Score: 2.5, Admin: true, Extra: map[string]string{"k": "v"}}
+
+	v, err := Marshal(user)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var decoded marshalUser
+	if err := Unmarshal(v, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, user) {
+		t.Errorf("Expected %+v, got %+v", user, decoded)
+	}
+}
+
+func TestUnmarshal_Embedded(t *testing.T) {
+	v, err := Marshal(marshalEmbedded{marshalBase: marshalBase{ID: 7}, Title: "t"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(v.Map) != 2 || v.Map[0].Key.Bulk != "id" {
+		t.Fatalf("Expected the embedded field to be promoted, got %+v", v.Map)
+	}
+
+	var decoded marshalEmbedded
+	if err := Unmarshal(v, &decoded); err != nil || decoded.ID != 7 || decoded.Title != "t" {
+		t.Errorf("Expected ID 7 and title 't', got %+v (err %v)", decoded, err)
+	}
+}
+
+func TestUnmarshal_RESP2Replies(t *testing.T) {
+	// HGETALL replies a flat array of fields and values where the numbers are bulk strings
+	reply := Value{Typ: "array", Array: []Value{
+		{Typ: "bulk", Bulk: "name"}, {Typ: "bulk", Bulk: "bob"},
+		{Typ: "bulk", Bulk: "AGE"}, {Typ: "bulk", Bulk: "41"},
+		{Typ: "bulk", Bulk: "admin"}, {Typ: "bulk", Bulk: "1"},
+		{Typ: "bulk", Bulk: "unknown"}, {Typ: "bulk", Bulk: "ignored"},
+	}}
+
+	var user marshalUser
+	if err := Unmarshal(reply, &user); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.Name != "bob" || user.Age != 41 || !user.Admin {
+		t.Errorf("Expected bob aged 41 and admin, got %+v", user)
+	}
+
+	var fields map[string]string
+	if err := Unmarshal(reply, &fields); err != nil || fields["AGE"] != "41" || len(fields) != 4 {
+		t.Errorf("Expected 4 fields with AGE 41, got %v (err %v)", fields, err)
+	}
+}
+
+func TestUnmarshal_Scalars(t *testing.T) {
+	var n int
+	if err := Unmarshal(Value{Typ: "integer", Num: 12}, &n); err != nil || n != 12 {
+		t.Errorf("Expected 12, got %d (err %v)", n, err)
+	}
+
+	var f float64
+	if err := Unmarshal(Value{Typ: "bulk", Bulk: "3.25"}, &f); err != nil || f != 3.25 {
+		t.Errorf("Expected 3.25, got %v (err %v)", f, err)
+	}
+
+	var b bool
+	if err := Unmarshal(NewNumberValue(1), &b); err != nil || !b {
+		t.Errorf("Expected true, got %v (err %v)", b, err)
+	}
+
+	var s string
+	if err := Unmarshal(NewNumberValue(5), &s); err != nil || s != "5" {
+		t.Errorf("Expected '5', got %q (err %v)", s, err)
+	}
+
+	var raw []byte
+	if err := Unmarshal(Value{Typ: "bulk", Bulk: "bytes"}, &raw); err != nil || string(raw) != "bytes" {
+		t.Errorf("Expected 'bytes', got %q (err %v)", raw, err)
+	}
+
+	var u upperString
+	if err := Unmarshal(Value{Typ: "string", Str: "LOUD"}, &u); err != nil || u != "loud" {
+		t.Errorf("Expected 'loud', got %q (err %v)", u, err)
+	}
+}
+
+func TestUnmarshal_Null(t *testing.T) {
+	p := new(string)
+	*p = "set"
+	if err := Unmarshal(NewNullValue(), &p); err != nil || p != nil {
+		t.Errorf("Expected a nil pointer, got %v (err %v)", p, err)
+	}
+
+	items := []string{"a"}
+	if err := Unmarshal(NewNullArrayValue(), &items); err != nil || items != nil {
+		t.Errorf("Expected a nil slice, got %v (err %v)", items, err)
+	}
+}
+
+func TestUnmarshal_Interface(t *testing.T) {
+	v := Value{Typ: "array", Array: []Value{
+		{Typ: "bulk", Bulk: "a"},
+		NewNumberValue(1),
+		NewNullValue(),
+		NewMapValue([]MapEntry{{Key: Value{Typ: "string", Str: "k"}, Value: NewDoubleValue(0.5)}}),
+	}}
+
+	var result any
+	if err := Unmarshal(v, &result); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []any{"a", int64(1), nil, map[string]any{"k": 0.5}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, result)
+	}
+}
+
+func TestUnmarshal_Errors(t *testing.T) {
+	var n int8
+	err := Unmarshal(NewNumberValue(1000), &n)
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("Expected UnmarshalTypeError for an overflow, got %v", err)
+	}
+
+	var user marshalUser
+	err = Unmarshal(NewMapValue([]MapEntry{{Key: Value{Typ: "bulk", Bulk: "age"}, Value: Value{Typ: "bulk", Bulk: "old"}}}), &user)
+	if !errors.As(err, &typeErr) || typeErr.Field != "age" {
+		t.Errorf("Expected UnmarshalTypeError on field age, got %v", err)
+	}
+
+	var s string
+	if err := Unmarshal(NewErrorValue("ERR no such key"), &s); err == nil || !strings.Contains(err.Error(), "ERR no such key") {
+		t.Errorf("Expected the error reply to be returned, got %v", err)
+	}
+	var replyErr *ReplyError
+	var natural any
+	nested := Value{Typ: KindArray, Array: []Value{NewNumberValue(1), NewErrorValue("WRONGTYPE nested")}}
+	if err := Unmarshal(nested, &natural); !errors.As(err, &replyErr) || replyErr.Message != "WRONGTYPE nested" {
+		t.Errorf("Expected a nested error reply as *ReplyError, got %v", err)
+	}
+	if err := Unmarshal(NewErrorValue("ERR no such key"), &s); !errors.As(err, &replyErr) || replyErr.Prefix() != "ERR" {
+		t.Errorf("Expected a *ReplyError, got %v", err)
+	}
+
+	var invalid *InvalidUnmarshalError
+	if err := Unmarshal(NewNumberValue(1), n); !errors.As(err, &invalid) {
+		t.Errorf("Expected InvalidUnmarshalError for a non pointer, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package goresp
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CommandFlags describe what a command does, like the flags of COMMAND INFO
+type CommandFlags uint
+
+const (
+	FlagReadonly CommandFlags = 1 << iota // the command only reads data
+	FlagWrite                             // the command may modify data
+	FlagAdmin                             // the command administers the server, like CONFIG or SHUTDOWN
+)
+
+var flagNames = []struct {
+	flag CommandFlags
+	name string
+}{
+	{FlagReadonly, "readonly"},
+	{FlagWrite, "write"},
+	{FlagAdmin, "admin"},
+}
+
+// Names returns the names of the flags as COMMAND INFO spells them
+func (f CommandFlags) Names() []string {
+	names := []string{}
+	for _, n := range flagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// CommandInfo is a command registered in a ServeMux
+type CommandInfo struct {
+	Name    string // lowercase, a subcommand is named "config|get" like in COMMAND INFO
+	Arity   int    // the number of arguments with the command name, negative means at least -Arity
+	Flags   CommandFlags
+	Handler Handler // nil for a command that only has subcommands
+
+	subcommands map[string]*CommandInfo
+}
+
+// Subcommands returns the subcommands of the command sorted by name
+func (c *CommandInfo) Subcommands() []*CommandInfo {
+	subs := make([]*CommandInfo, 0, len(c.subcommands))
+	for _, sub := range c.subcommands {
+		subs = append(subs, sub)
+	}
+	slices.SortFunc(subs, func(a, b *CommandInfo) int { return strings.Compare(a.Name, b.Name) })
+	return subs
+}
+
+func (c *CommandInfo) acceptsArgs(n int) bool {
+	if c.Arity < 0 {
+		return n >= -c.Arity
+	}
+	return n == c.Arity
+}
+
+// ServeMux routes commands to the handler registered for their name, the lookup is case insensitive
+// it replies the errors of redis itself to unknown commands and to commands with the wrong number of arguments
+type ServeMux struct {
+	mu       sync.RWMutex
+	commands map[string]*CommandInfo // keyed by lowercase name
+}
+
+// NewServeMux creates an empty ServeMux
+func NewServeMux() *ServeMux {
+	return &ServeMux{commands: map[string]*CommandInfo{}}
+}
+
+// Handle registers the handler of a command, arity follows redis: it counts the command name, 3 for SET key value,
+// and a negative arity is a minimum, -2 for DEL key [key ...]
+//
+// a subcommand is registered with its full name like "CONFIG GET", its arity counts both words,
+// if the parent command has no handler of its own the unknown subcommands are replied an error
+//
+// Handle panics if the command is already registered or the arity can't be met, like http.ServeMux does on bad patterns
+func (m *ServeMux) Handle(name string, arity int, flags CommandFlags, handler Handler) {
+	words := strings.Fields(strings.ToLower(name))
+	if len(words) == 0 || len(words) > 2 {
+		panic("goresp: invalid command name " + Quote(name))
+	}
+	if handler == nil {
+		panic("goresp: nil handler for command " + name)
+	}
+	if arity == 0 || (arity > 0 && arity < len(words)) || (arity < 0 && -arity < len(words)) {
+		panic("goresp: invalid arity for command " + name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cmd := m.commands[words[0]]
+	if cmd == nil {
+		// the parent of a subcommand takes at least the subcommand name
+		cmd = &CommandInfo{Name: words[0], Arity: -2}
+		m.commands[words[0]] = cmd
+	}
+
+	if len(words) == 1 {
+		if cmd.Handler != nil {
+			panic("goresp: multiple registrations for command " + name)
+		}
+		// a copy, a request being served may hold the parent
+		m.commands[words[0]] = &CommandInfo{Name: words[0], Arity: arity, Flags: flags, Handler: handler, subcommands: cmd.subcommands}
+		return
+	}
+
+	if cmd.subcommands == nil {
+		cmd.subcommands = map[string]*CommandInfo{}
+	}
+	if cmd.subcommands[words[1]] != nil {
+		panic("goresp: multiple registrations for command " + name)
+	}
+	cmd.subcommands[words[1]] = &CommandInfo{Name: words[0] + "|" + words[1], Arity: arity, Flags: flags, Handler: handler}
+}
+
+// HandleFunc registers a function as the handler of a command, see Handle
+func (m *ServeMux) HandleFunc(name string, arity int, flags CommandFlags, handler func(w ReplyWriter, r *Request)) {
+	m.Handle(name, arity, flags, HandlerFunc(handler))
+}
+
+// Lookup returns the command args are routed to, the subcommand when args[1] names one, or nil for an unknown command
+func (m *ServeMux) Lookup(args []string) *CommandInfo {
+	if len(args) == 0 {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cmd := m.commands[strings.ToLower(args[0])]
+	if cmd != nil && cmd.subcommands != nil && len(args) > 1 {
+		if sub := cmd.subcommands[strings.ToLower(args[1])]; sub != nil {
+			return sub
+		}
+	}
+	return cmd
+}
+
+// Commands returns the registered commands sorted by name, their subcommands are listed by CommandInfo.Subcommands
+func (m *ServeMux) Commands() []*CommandInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cmds := make([]*CommandInfo, 0, len(m.commands))
+	for _, cmd := range m.commands {
+		cmds = append(cmds, cmd)
+	}
+	slices.SortFunc(cmds, func(a, b *CommandInfo) int { return strings.Compare(a.Name, b.Name) })
+	return cmds
+}
+
+// ServeRESP routes the request to its handler and sets r.Info, or replies the error redis would
+func (m *ServeMux) ServeRESP(w ReplyWriter, r *Request) {
+	cmd := m.Lookup(r.Args)
+	if cmd == nil {
+		w.WriteError(unknownCommandError(r.Args))
+		return
+	}
+
+	if cmd.Handler == nil {
+		// a parent without a handler, args[1] is missing or isn't one of its subcommands
+		if len(r.Args) < 2 {
+			w.WriteError(wrongArityError(cmd.Name))
+		} else {
+			w.WriteError("ERR unknown subcommand '" + truncate(r.Args[1], 128) + "'. Try " + strings.ToUpper(r.Args[0]) + " HELP.")
+		}
+		return
+	}
+	if !cmd.acceptsArgs(len(r.Args)) {
+		w.WriteError(wrongArityError(cmd.Name))
+		return
+	}
+
+	r.Info = cmd
+	cmd.Handler.ServeRESP(w, r)
+}
+
+// the error of redis for an unknown command, it quotes the start of the arguments
+func unknownCommandError(args []string) string {
+	var name string
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	var quoted strings.Builder
+	for _, arg := range args {
+		if quoted.Len() >= 128 {
+			break
+		}
+		quoted.WriteString("'" + truncate(arg, 128-quoted.Len()) + "' ")
+	}
+
+	return "ERR unknown command '" + truncate(name, 128) + "', with args beginning with: " + quoted.String()
+}
+
+func wrongArityError(name string) string {
+	return "ERR wrong number of arguments for '" + name + "' command"
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,163 @@
+package goresp
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// replies with the name of the matched command so the tests can tell where a request was routed
+func echoInfo(w ReplyWriter, r *Request) {
+	w.WriteBulk(r.Info.Name)
+}
+
+func newTestMux() *ServeMux {
+	mux := NewServeMux()
+	mux.HandleFunc("GET", 2, FlagReadonly, echoInfo)
+	mux.HandleFunc("set", -3, FlagWrite, echoInfo)
+	mux.HandleFunc("CONFIG GET", -3, FlagAdmin, echoInfo)
+	mux.HandleFunc("CONFIG SET", -4, FlagAdmin, echoInfo)
+	mux.HandleFunc("CLIENT", -2, FlagAdmin, echoInfo)
+	mux.HandleFunc("CLIENT ID", 2, FlagAdmin, echoInfo)
+	return mux
+}
+
+func serve(t *testing.T, h Handler, args ...string) Value {
+	t.Helper()
+	var rec ReplyRecorder
+	h.ServeRESP(&rec, NewRequest(context.Background(), args...))
+
+	values, err := rec.Values()
+	if err != nil || len(values) != 1 {
+		t.Fatalf("%v was replied %q (%v), want a single reply", args, rec.Bytes(), err)
+	}
+	return values[0]
+}
+
+func TestServeMux_ServeRESP(t *testing.T) {
+	mux := newTestMux()
+
+	tests := []struct {
+		args []string
+		want Value
+	}{
+		{[]string{"GET", "k"}, Value{Typ: KindBulk, Bulk: "get"}},
+		{[]string{"gEt", "k"}, Value{Typ: KindBulk, Bulk: "get"}},
+		{[]string{"SET", "k", "v", "EX", "10"}, Value{Typ: KindBulk, Bulk: "set"}},
+		{[]string{"config", "Get", "maxmemory"}, Value{Typ: KindBulk, Bulk: "config|get"}},
+		{[]string{"CONFIG", "SET", "maxmemory", "1"}, Value{Typ: KindBulk, Bulk: "config|set"}},
+		{[]string{"CLIENT", "ID"}, Value{Typ: KindBulk, Bulk: "client|id"}},
+		// CLIENT has a handler of its own for the subcommands that aren't registered
+		{[]string{"CLIENT", "LIST"}, Value{Typ: KindBulk, Bulk: "client"}},
+
+		{[]string{"NOPE", "a", "b"}, Value{Typ: KindError, Str: "ERR unknown command 'NOPE', with args beginning with: 'a' 'b' "}},
+		{[]string{"NOPE"}, Value{Typ: KindError, Str: "ERR unknown command 'NOPE', with args beginning with: "}},
+		{[]string{"GET"}, Value{Typ: KindError, Str: "ERR wrong number of arguments for 'get' command"}},
+		{[]string{"GET", "a", "b"}, Value{Typ: KindError, Str: "ERR wrong number of arguments for 'get' command"}},
+		{[]string{"SET", "k"}, Value{Typ: KindError, Str: "ERR wrong number of arguments for 'set' command"}},
+		{[]string{"CONFIG", "GET"}, Value{Typ: KindError, Str: "ERR wrong number of arguments for 'config|get' command"}},
+		{[]string{"CONFIG"}, Value{Typ: KindError, Str: "ERR wrong number of arguments for 'config' command"}},
+		{[]string{"config", "nope"}, Value{Typ: KindError, Str: "ERR unknown subcommand 'nope'. Try CONFIG HELP."}},
+		{[]string{"CLIENT", "ID", "extra"}, Value{Typ: KindError, Str: "ERR wrong number of arguments for 'client|id' command"}},
+	}
+
+	for _, tt := range tests {
+		if got := serve(t, mux, tt.args...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v was replied %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestServeMux_ServeRESP_LongUnknownCommand(t *testing.T) {
+	long := strings.Repeat("x", 300)
+
+	got := serve(t, NewServeMux(), long, long, long)
+
+	want := "ERR unknown command '" + long[:128] + "', with args beginning with: '" + long[:128] + "' "
+	if got.Str != want {
+		t.Errorf("the error is %d bytes long, want the name and arguments truncated to %d bytes", len(got.Str), len(want))
+	}
+}
+
+func TestServeMux_Lookup(t *testing.T) {
+	mux := newTestMux()
+
+	if cmd := mux.Lookup([]string{"config", "get", "x"}); cmd == nil || cmd.Name != "config|get" || cmd.Arity != -3 || cmd.Flags != FlagAdmin {
+		t.Errorf("Lookup(CONFIG GET) = %+v", cmd)
+	}
+	if cmd := mux.Lookup([]string{"GET", "k"}); cmd == nil || cmd.Flags != FlagReadonly {
+		t.Errorf("Lookup(GET) = %+v", cmd)
+	}
+	if cmd := mux.Lookup([]string{"nope"}); cmd != nil {
+		t.Errorf("Lookup(nope) = %+v, want nil", cmd)
+	}
+	if cmd := mux.Lookup(nil); cmd != nil {
+		t.Errorf("Lookup(nil) = %+v, want nil", cmd)
+	}
+}
+
+func TestServeMux_Commands(t *testing.T) {
+	mux := newTestMux()
+
+	var names []string
+	for _, cmd := range mux.Commands() {
+		names = append(names, cmd.Name)
+		for _, sub := range cmd.Subcommands() {
+			names = append(names, sub.Name)
+		}
+	}
+
+	want := []string{"client", "client|id", "config", "config|get", "config|set", "get", "set"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Commands() = %v, want %v", names, want)
+	}
+}
+
+func TestServeMux_Handle_Panics(t *testing.T) {
+	tests := []struct {
+		name  string
+		arity int
+	}{
+		{"", 1},
+		{"a b c", -3},
+		{"GET", 0},
+		{"CONFIG GET", 1},
+		{"CONFIG GET", -1},
+		{"get", 2}, // registered by newTestMux
+		{"CONFIG SET", -4},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Handle(%q, %d) didn't panic", tt.name, tt.arity)
+				}
+			}()
+			newTestMux().HandleFunc(tt.name, tt.arity, 0, echoInfo)
+		}()
+	}
+}
+
+func TestServeMux_Handle_ParentAfterSubcommands(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("CONFIG GET", -3, FlagAdmin, echoInfo)
+	mux.HandleFunc("CONFIG", -2, FlagAdmin, echoInfo)
+
+	if got := serve(t, mux, "CONFIG", "GET", "x"); got.Bulk != "config|get" {
+		t.Errorf("CONFIG GET was routed to %+v", got)
+	}
+	if got := serve(t, mux, "CONFIG", "HELP"); got.Bulk != "config" {
+		t.Errorf("CONFIG HELP was routed to %+v, want the parent handler", got)
+	}
+}
+
+func TestCommandFlags_Names(t *testing.T) {
+	if got := (FlagReadonly | FlagAdmin).Names(); !reflect.DeepEqual(got, []string{"readonly", "admin"}) {
+		t.Errorf("Names() = %v", got)
+	}
+	if got := CommandFlags(0).Names(); len(got) != 0 {
+		t.Errorf("Names() of no flag = %v", got)
+	}
+}
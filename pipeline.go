@@ -0,0 +1,83 @@
+package goresp
+
+import "context"
+
+// PipelineCmd is a command queued in a Pipeline, Exec fills in its reply
+type PipelineCmd struct {
+	Args  []any
+	Reply Value
+	Err   error // a *ReplyError for an error reply, or why the command couldn't be sent or its reply read
+}
+
+// Pipeline queues commands and sends them to the server in a single write, then reads all their replies
+// it is not safe for concurrent use, but other callers of the Client can't interleave with Exec
+type Pipeline struct {
+	client *Client
+	buf    []byte         // the encoded commands
+	cmds   []*PipelineCmd // every queued command, the ones that failed to encode included
+	sent   []*PipelineCmd // the commands in buf, one reply is read for each
+}
+
+// Pipeline returns an empty pipeline that runs on the connection of c
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: c}
+}
+
+// Queue adds a command, its arguments are encoded like for Client.Do
+// an argument that can't be encoded fails only this command, it is not sent and the others run as usual
+func (p *Pipeline) Queue(args ...any) *PipelineCmd {
+	cmd := &PipelineCmd{Args: args}
+	p.cmds = append(p.cmds, cmd)
+
+	buf, err := appendCommand(p.buf, args)
+	if err != nil {
+		cmd.Err = err
+		return cmd
+	}
+	p.buf = buf
+	p.sent = append(p.sent, cmd)
+
+	return cmd
+}
+
+// Len returns the number of queued commands
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends the queued commands and reads exactly one reply per command sent, then empties the pipeline so it can be reused
+// it returns the commands in the order they were queued and the first of their errors
+//
+// error replies are set on their command and leave the connection usable, a failure of the connection
+// is set on every command whose reply was not read and closes the client like Client.Do does
+func (p *Pipeline) Exec(ctx context.Context) ([]*PipelineCmd, error) {
+	cmds, sent, buf := p.cmds, p.sent, p.buf
+	p.cmds, p.sent, p.buf = nil, nil, buf[:0]
+
+	if len(sent) > 0 {
+		c := p.client
+		c.mu.Lock()
+		var replies []Value
+		err := c.err
+		if err == nil {
+			replies, err = c.roundTrip(ctx, buf, len(sent))
+		}
+		c.mu.Unlock()
+
+		for i, cmd := range sent {
+			if i >= len(replies) {
+				cmd.Err = err
+				continue
+			}
+			cmd.Reply = replies[i]
+			cmd.Err = replies[i].Err()
+		}
+	}
+
+	for _, cmd := range cmds {
+		if cmd.Err != nil {
+			return cmds, cmd.Err
+		}
+	}
+	return cmds, nil
+}
@@ -0,0 +1,163 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counts the writes made on a connection
+type writeCountingConn struct {
+	net.Conn
+	writes atomic.Int32
+}
+
+func (c *writeCountingConn) Write(p []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(p)
+}
+
+func TestPipeline_Exec(t *testing.T) {
+	s := newFakeServer(t, "tcp", newFakeStore())
+	network, address := s.addr()
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	counting := &writeCountingConn{Conn: conn}
+	client := NewClient(counting)
+	defer client.Close()
+
+	pipe := client.Pipeline()
+	set := pipe.Queue("SET", "k", "v")
+	get := pipe.Queue("GET", "k")
+	incr := pipe.Queue("INCR", "k")
+	missing := pipe.Queue("GET", "missing")
+	if pipe.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", pipe.Len())
+	}
+
+	cmds, err := pipe.Exec(context.Background())
+
+	var replyErr *ReplyError
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("Exec() returned %v, want the error reply of INCR", err)
+	}
+	if len(cmds) != 4 || cmds[0] != set || cmds[3] != missing {
+		t.Fatalf("Exec() returned %v, want the 4 queued commands in order", cmds)
+	}
+	if set.Err != nil || set.Reply.Str != "OK" {
+		t.Errorf("SET = %+v, want OK", set)
+	}
+	if get.Err != nil || get.Reply.Bulk != "v" {
+		t.Errorf("GET = %+v, want v", get)
+	}
+	if !errors.As(incr.Err, &replyErr) || !incr.Reply.IsError() {
+		t.Errorf("INCR = %+v, want its error reply", incr)
+	}
+	if missing.Err != nil || !missing.Reply.IsNull() {
+		t.Errorf("GET missing = %+v, want null", missing)
+	}
+	if writes := counting.writes.Load(); writes != 1 {
+		t.Errorf("the pipeline was sent in %d writes, want 1", writes)
+	}
+
+	// the error reply didn't desync the connection
+	if reply, err := client.Do(context.Background(), "PING"); err != nil || reply.Str != "PONG" {
+		t.Errorf("Do(PING) after the pipeline = %+v, %v, want PONG", reply, err)
+	}
+}
+
+func TestPipeline_Exec_Reuse(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+	pipe := client.Pipeline()
+
+	pipe.Queue("INCR", "n")
+	pipe.Queue("INCR", "n")
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		t.Fatalf("Exec() returned error %v", err)
+	}
+	if pipe.Len() != 0 {
+		t.Fatalf("Len() after Exec = %d, want 0", pipe.Len())
+	}
+
+	incr := pipe.Queue("INCR", "n")
+	cmds, err := pipe.Exec(context.Background())
+	if err != nil || len(cmds) != 1 || incr.Reply.Num != 3 {
+		t.Errorf("second Exec() = %v, %v, want INCR to reply 3", incr, err)
+	}
+}
+
+func TestPipeline_Exec_Empty(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+
+	cmds, err := client.Pipeline().Exec(context.Background())
+	if err != nil || len(cmds) != 0 {
+		t.Errorf("Exec() of an empty pipeline = %v, %v", cmds, err)
+	}
+}
+
+func TestPipeline_Queue_UnsupportedArgument(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+	pipe := client.Pipeline()
+
+	first := pipe.Queue("SET", "k", "1")
+	bad := pipe.Queue("SET", "k", struct{}{})
+	last := pipe.Queue("GET", "k")
+
+	_, err := pipe.Exec(context.Background())
+
+	var typeErr *UnsupportedTypeError
+	if !errors.As(err, &typeErr) || !errors.As(bad.Err, &typeErr) {
+		t.Fatalf("Exec() returned %v and the bad command %v, want an UnsupportedTypeError", err, bad.Err)
+	}
+	if first.Err != nil || last.Err != nil || last.Reply.Bulk != "1" {
+		t.Errorf("the other commands should run, got %+v and %+v", first, last)
+	}
+	if reply, err := client.Do(context.Background(), "PING"); err != nil || reply.Str != "PONG" {
+		t.Errorf("Do(PING) after the pipeline = %+v, %v, want PONG", reply, err)
+	}
+}
+
+func TestPipeline_Exec_ConnectionFailure(t *testing.T) {
+	// the server answers the first command of the pipeline and then goes silent
+	answered := atomic.Bool{}
+	client := dialFake(t, newFakeServer(t, "tcp", func(args []string) Value {
+		if answered.CompareAndSwap(false, true) {
+			return Value{Typ: KindString, Str: "OK"}
+		}
+		return Value{}
+	}))
+
+	pipe := client.Pipeline()
+	first := pipe.Queue("SET", "a", "1")
+	second := pipe.Queue("SET", "b", "2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := pipe.Exec(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(second.Err, context.DeadlineExceeded) {
+		t.Fatalf("Exec() returned %v and the unanswered command %v, want context.DeadlineExceeded", err, second.Err)
+	}
+	if first.Err != nil || first.Reply.Str != "OK" {
+		t.Errorf("the reply read before the failure should be kept, got %+v", first)
+	}
+	if _, err := client.Do(context.Background(), "PING"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Do() after a failed pipeline returned %v, want ErrClosed", err)
+	}
+}
+
+func TestPipeline_Exec_ClosedClient(t *testing.T) {
+	client := dialFake(t, newFakeServer(t, "tcp", newFakeStore()))
+	client.Close()
+
+	pipe := client.Pipeline()
+	cmd := pipe.Queue("PING")
+	if _, err := pipe.Exec(context.Background()); !errors.Is(err, ErrClosed) || !errors.Is(cmd.Err, ErrClosed) {
+		t.Errorf("Exec() on a closed client returned %v, want ErrClosed", err)
+	}
+}
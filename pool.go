@@ -0,0 +1,343 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by the Get and Do of a closed Pool
+var ErrPoolClosed = errors.New("goresp: pool closed")
+
+// PoolOptions configure a Pool, the zero value keeps up to 2 idle connections and never closes them for their age
+type PoolOptions struct {
+	Client ClientOptions // the options of the connections the pool dials
+
+	// Dial replaces the dialing of network and address, tests and custom transports like TLS use it
+	Dial func(ctx context.Context) (*Client, error)
+
+	MaxOpen int // the most connections open at once, checked out or idle, Get waits when it is reached, 0 means no limit
+	MinIdle int // the idle connections dialled ahead of time, they are kept up in the background
+	MaxIdle int // the most idle connections kept, the others are closed when they are put back, 0 means DefaultMaxIdle, it is raised to MinIdle
+
+	MaxLifetime time.Duration // connections older than this are closed instead of reused, 0 means no limit
+	IdleTimeout time.Duration // idle connections unused for this long are closed, 0 means no limit
+
+	// HealthCheck is how long a connection must have been idle to be checked with a PING when it is taken, 0 checks every time
+	HealthCheck time.Duration
+}
+
+// the MaxIdle of a pool that doesn't set it, like database/sql
+const DefaultMaxIdle = 2
+
+// PoolStats are the counters of a Pool, they are meant to be exported as metrics
+type PoolStats struct {
+	Hits     uint64 // Get reused an idle connection
+	Misses   uint64 // Get dialled a new connection
+	Timeouts uint64 // Get gave up because its context ended while it waited for a connection
+
+	TotalConns int // the open connections, checked out or idle
+	IdleConns  int // the idle connections
+}
+
+// Pool shares a set of Client connections between callers, each command gets a connection of its own
+type Pool struct {
+	dial func(ctx context.Context) (*Client, error)
+	opts PoolOptions
+
+	mu      sync.Mutex
+	idle    []*Client      // the most recently used connection is at the end
+	open    int            // the connections counted in MaxOpen, dialling ones included
+	waiters []chan *Client // the Get waiting for a connection, oldest first
+	stats   PoolStats      // TotalConns and IdleConns are filled in by Stats
+	closed  bool
+	done    chan struct{} // closed by Close to stop the reaper
+}
+
+// NewPool creates a pool of connections to a server, network is "tcp" or "unix" like for Dial
+func NewPool(network, address string, opts PoolOptions) *Pool {
+	if opts.MaxIdle == 0 {
+		opts.MaxIdle = DefaultMaxIdle
+	}
+	// otherwise the connections the reaper dials for MinIdle are closed as soon as they are put back
+	opts.MaxIdle = max(opts.MaxIdle, opts.MinIdle)
+	p := &Pool{opts: opts, dial: opts.Dial, done: make(chan struct{})}
+	if p.dial == nil {
+		p.dial = func(ctx context.Context) (*Client, error) {
+			return DialWithOptions(ctx, network, address, opts.Client)
+		}
+	}
+
+	if opts.MinIdle > 0 || opts.IdleTimeout > 0 || opts.MaxLifetime > 0 {
+		go p.reaper()
+	}
+
+	return p
+}
+
+// Do runs a single command on a connection of the pool, it is Get, Client.Do and Put together
+func (p *Pool) Do(ctx context.Context, args ...any) (Value, error) {
+	c, err := p.Get(ctx)
+	if err != nil {
+		return Value{}, err
+	}
+	defer p.Put(c)
+
+	return c.Do(ctx, args...)
+}
+
+// Get takes a connection out of the pool, it must be given back with Put
+// when MaxOpen connections are in use it waits for one to be put back or for ctx to end
+func (p *Pool) Get(ctx context.Context) (*Client, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if n := len(p.idle); n > 0 {
+			c := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+
+			if !p.healthy(ctx, c) {
+				p.discard(c)
+				continue
+			}
+			p.mu.Lock()
+			p.stats.Hits++
+			p.mu.Unlock()
+			return c, nil
+		}
+
+		if p.opts.MaxOpen == 0 || p.open < p.opts.MaxOpen {
+			p.open++
+			p.stats.Misses++
+			p.mu.Unlock()
+			return p.dialSlot(ctx)
+		}
+
+		// every connection is in use, wait for Put to hand one over or to free its slot
+		req := make(chan *Client, 1)
+		p.waiters = append(p.waiters, req)
+		p.mu.Unlock()
+
+		select {
+		case c, ok := <-req:
+			// Close closes the channels of the waiting Get
+			if !ok {
+				return nil, ErrPoolClosed
+			}
+			if c != nil {
+				p.mu.Lock()
+				p.stats.Hits++
+				p.mu.Unlock()
+				return c, nil
+			}
+			// the slot of a closed connection was handed over
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				p.releaseSlot()
+				return nil, ErrPoolClosed
+			}
+			p.stats.Misses++
+			p.mu.Unlock()
+			return p.dialSlot(ctx)
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.stats.Timeouts++
+			i := slices.Index(p.waiters, req)
+			if i >= 0 {
+				p.waiters = slices.Delete(p.waiters, i, i+1)
+			}
+			p.mu.Unlock()
+			// Put handed something over just before ctx ended, pass it on
+			if i < 0 {
+				if c, ok := <-req; c != nil {
+					p.Put(c)
+				} else if ok {
+					p.releaseSlot()
+				}
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Put gives back a connection taken with Get, a connection that failed or outlived MaxLifetime is closed
+func (p *Pool) Put(c *Client) {
+	now := time.Now()
+
+	p.mu.Lock()
+	if p.closed || c.broken() || p.expired(c, now) {
+		p.mu.Unlock()
+		p.discard(c)
+		return
+	}
+	c.used = now
+
+	if len(p.waiters) > 0 {
+		req := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.mu.Unlock()
+		req <- c
+		return
+	}
+	if len(p.idle) < p.opts.MaxIdle {
+		p.idle = append(p.idle, c)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	p.discard(c)
+}
+
+// Stats returns a snapshot of the counters of the pool
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.TotalConns = p.open
+	stats.IdleConns = len(p.idle)
+	return stats
+}
+
+// Close closes the idle connections and makes the waiting and future Get fail,
+// the connections still checked out are closed when they are put back
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	idle := p.idle
+	p.idle = nil
+	waiters := p.waiters
+	p.waiters = nil
+	p.mu.Unlock()
+
+	for _, req := range waiters {
+		close(req)
+	}
+	var err error
+	for _, c := range idle {
+		err = errors.Join(err, c.Close())
+		p.releaseSlot()
+	}
+
+	return err
+}
+
+// dials a connection for a slot already counted in open
+func (p *Pool) dialSlot(ctx context.Context) (*Client, error) {
+	c, err := p.dial(ctx)
+	if err != nil {
+		p.releaseSlot()
+		return nil, err
+	}
+	c.created = time.Now()
+	c.used = c.created
+	return c, nil
+}
+
+// closes a connection and frees its slot
+func (p *Pool) discard(c *Client) {
+	c.Close()
+	p.releaseSlot()
+}
+
+// frees a slot of MaxOpen, a waiting Get takes it over to dial a connection of its own
+func (p *Pool) releaseSlot() {
+	p.mu.Lock()
+	if len(p.waiters) > 0 {
+		req := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.mu.Unlock()
+		req <- nil
+		return
+	}
+	p.open--
+	p.mu.Unlock()
+}
+
+func (p *Pool) expired(c *Client, now time.Time) bool {
+	return p.opts.MaxLifetime > 0 && now.Sub(c.created) >= p.opts.MaxLifetime
+}
+
+// checks a connection taken from the idle list before it is handed out
+func (p *Pool) healthy(ctx context.Context, c *Client) bool {
+	now := time.Now()
+	if p.expired(c, now) || (p.opts.IdleTimeout > 0 && now.Sub(c.used) >= p.opts.IdleTimeout) {
+		return false
+	}
+	if now.Sub(c.used) < p.opts.HealthCheck {
+		return true
+	}
+
+	reply, err := c.Do(ctx, "PING")
+	return err == nil && reply.Typ == KindString
+}
+
+// closes the stale idle connections and keeps MinIdle connections ready in the background
+func (p *Pool) reaper() {
+	interval := time.Minute
+	for _, d := range []time.Duration{p.opts.IdleTimeout, p.opts.MaxLifetime} {
+		if d > 0 && d/2 < interval {
+			interval = max(d/2, time.Second)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.reap()
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *Pool) reap() {
+	now := time.Now()
+
+	p.mu.Lock()
+	var stale []*Client
+	p.idle = slices.DeleteFunc(p.idle, func(c *Client) bool {
+		if p.expired(c, now) || (p.opts.IdleTimeout > 0 && now.Sub(c.used) >= p.opts.IdleTimeout) {
+			stale = append(stale, c)
+			return true
+		}
+		return false
+	})
+	missing := p.opts.MinIdle - len(p.idle)
+	if p.opts.MaxOpen > 0 {
+		missing = min(missing, p.opts.MaxOpen-p.open)
+	}
+	missing = max(missing, 0)
+	p.open += missing
+	p.mu.Unlock()
+
+	for _, c := range stale {
+		p.discard(c)
+	}
+	for i := 0; i < missing; i++ {
+		c, err := p.dialSlot(context.Background())
+		if err != nil {
+			// the slots of the remaining dials are freed too, the next round tries again
+			for ; i < missing-1; i++ {
+				p.releaseSlot()
+			}
+			return
+		}
+		p.Put(c)
+	}
+}
@@ -0,0 +1,292 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newFakePool(t *testing.T, s *fakeServer, opts PoolOptions) *Pool {
+	t.Helper()
+	network, address := s.addr()
+	p := NewPool(network, address, opts)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// closes the server side of every connection like a server restart does
+func (s *fakeServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func TestPool_Do_ReusesConnections(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{})
+	ctx := context.Background()
+
+	if _, err := p.Do(ctx, "SET", "k", "v"); err != nil {
+		t.Fatalf("Do(SET) returned error %v", err)
+	}
+	reply, err := p.Do(ctx, "GET", "k")
+	if err != nil || reply.Bulk != "v" {
+		t.Fatalf("Do(GET) = %+v, %v, want v", reply, err)
+	}
+
+	stats := p.Stats()
+	if stats.Misses != 1 || stats.Hits != 1 || stats.TotalConns != 1 || stats.IdleConns != 1 {
+		t.Errorf("Stats() = %+v, want 1 miss, 1 hit and a single idle connection", stats)
+	}
+}
+
+func TestPool_Do_ErrorReplyKeepsTheConnection(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{})
+
+	var replyErr *ReplyError
+	if _, err := p.Do(context.Background(), "NOPE"); !errors.As(err, &replyErr) {
+		t.Fatalf("Do() returned %v, want a *ReplyError", err)
+	}
+	if stats := p.Stats(); stats.IdleConns != 1 {
+		t.Errorf("Stats() = %+v, the connection should be back in the pool", stats)
+	}
+}
+
+func TestPool_Get_WaitsWhenExhausted(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{MaxOpen: 1})
+
+	c, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() returned error %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() on an exhausted pool returned %v, want context.DeadlineExceeded", err)
+	}
+	if stats := p.Stats(); stats.Timeouts != 1 || stats.TotalConns != 1 {
+		t.Errorf("Stats() = %+v, want 1 timeout and 1 connection", stats)
+	}
+
+	got := make(chan *Client)
+	go func() {
+		c, err := p.Get(context.Background())
+		if err != nil {
+			t.Errorf("Get() returned error %v", err)
+		}
+		got <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Put(c)
+
+	if waiter := <-got; waiter != c {
+		t.Errorf("the waiting Get should receive the connection that was put back")
+	}
+}
+
+func TestPool_Get_WaiterDialsWhenAConnectionIsDiscarded(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{MaxOpen: 1})
+
+	c, _ := p.Get(context.Background())
+
+	got := make(chan error)
+	go func() {
+		c, err := p.Get(context.Background())
+		if err == nil {
+			_, err = c.Do(context.Background(), "PING")
+			p.Put(c)
+		}
+		got <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	c.Close()
+	p.Put(c)
+
+	if err := <-got; err != nil {
+		t.Fatalf("the waiting Get failed with %v", err)
+	}
+	if stats := p.Stats(); stats.TotalConns != 1 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 1 connection after 2 dials", stats)
+	}
+}
+
+func TestPool_Put_DiscardsBrokenConnections(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{})
+
+	c, _ := p.Get(context.Background())
+	c.Close()
+	p.Put(c)
+
+	if stats := p.Stats(); stats.TotalConns != 0 || stats.IdleConns != 0 {
+		t.Errorf("Stats() = %+v, the closed connection should be dropped", stats)
+	}
+}
+
+func TestPool_Put_MaxIdle(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{MaxIdle: 1})
+
+	c1, _ := p.Get(context.Background())
+	c2, _ := p.Get(context.Background())
+	p.Put(c1)
+	p.Put(c2)
+
+	if stats := p.Stats(); stats.TotalConns != 1 || stats.IdleConns != 1 {
+		t.Errorf("Stats() = %+v, want a single idle connection", stats)
+	}
+}
+
+func TestPool_Get_HealthCheck(t *testing.T) {
+	s := newFakeServer(t, "tcp", newFakeStore())
+	p := newFakePool(t, s, PoolOptions{})
+
+	if _, err := p.Do(context.Background(), "PING"); err != nil {
+		t.Fatalf("Do() returned error %v", err)
+	}
+	s.dropConns()
+
+	// the PING on checkout finds the dead connection and a new one is dialled
+	if reply, err := p.Do(context.Background(), "PING"); err != nil || reply.Str != "PONG" {
+		t.Fatalf("Do() after the server dropped the connection = %+v, %v, want PONG", reply, err)
+	}
+	if stats := p.Stats(); stats.Misses != 2 || stats.Hits != 0 || stats.TotalConns != 1 {
+		t.Errorf("Stats() = %+v, want 2 misses and a single connection", stats)
+	}
+}
+
+func TestPool_Get_MaxLifetime(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{MaxLifetime: 20 * time.Millisecond})
+
+	c, _ := p.Get(context.Background())
+	p.Put(c)
+	time.Sleep(30 * time.Millisecond)
+
+	c2, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() returned error %v", err)
+	}
+	if c2 == c {
+		t.Error("Get() returned a connection older than MaxLifetime")
+	}
+	p.Put(c2)
+}
+
+func TestPool_Reap(t *testing.T) {
+	var dials atomic.Int32
+	s := newFakeServer(t, "tcp", newFakeStore())
+	network, address := s.addr()
+	opts := PoolOptions{
+		MinIdle:     2,
+		IdleTimeout: time.Hour,
+		Dial: func(ctx context.Context) (*Client, error) {
+			dials.Add(1)
+			return Dial(ctx, network, address)
+		},
+	}
+	p := newFakePool(t, s, opts)
+
+	// the reaper dials MinIdle connections in the background
+	for deadline := time.Now().Add(time.Second); p.Stats().IdleConns < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := p.Stats(); stats.IdleConns != 2 || stats.Misses != 0 {
+		t.Fatalf("Stats() = %+v, want 2 idle connections dialled ahead of time", stats)
+	}
+
+	// the idle connections time out and are replaced
+	p.mu.Lock()
+	for _, c := range p.idle {
+		c.used = time.Now().Add(-2 * time.Hour)
+	}
+	p.mu.Unlock()
+	p.reap()
+
+	if stats := p.Stats(); stats.IdleConns != 2 || stats.TotalConns != 2 || dials.Load() != 4 {
+		t.Errorf("Stats() = %+v after %d dials, want the 2 stale connections replaced", stats, dials.Load())
+	}
+}
+
+func TestPool_MinIdleAboveMaxIdle(t *testing.T) {
+	var dials atomic.Int32
+	s := newFakeServer(t, "tcp", newFakeStore())
+	network, address := s.addr()
+	p := newFakePool(t, s, PoolOptions{
+		MinIdle: 5,
+		Dial: func(ctx context.Context) (*Client, error) {
+			dials.Add(1)
+			return Dial(ctx, network, address)
+		},
+	})
+
+	for deadline := time.Now().Add(time.Second); p.Stats().IdleConns < 5 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	p.reap()
+
+	if stats := p.Stats(); stats.IdleConns != 5 || stats.TotalConns != 5 || dials.Load() != 5 {
+		t.Errorf("Stats() = %+v after %d dials, want the 5 connections of MinIdle kept", stats, dials.Load())
+	}
+}
+
+func TestPool_Close(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{MaxOpen: 1})
+
+	c, _ := p.Get(context.Background())
+	waiter := make(chan error)
+	go func() {
+		_, err := p.Get(context.Background())
+		waiter <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() returned error %v", err)
+	}
+	if err := <-waiter; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("the waiting Get returned %v, want ErrPoolClosed", err)
+	}
+	if _, err := p.Get(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Get() after Close returned %v, want ErrPoolClosed", err)
+	}
+
+	p.Put(c)
+	if _, err := c.Do(context.Background(), "PING"); !errors.Is(err, ErrClosed) {
+		t.Errorf("a connection put back after Close should be closed, Do returned %v", err)
+	}
+	if stats := p.Stats(); stats.TotalConns != 0 {
+		t.Errorf("Stats() = %+v, want no connection left", stats)
+	}
+}
+
+func TestPool_Do_Concurrent(t *testing.T) {
+	p := newFakePool(t, newFakeServer(t, "tcp", newFakeStore()), PoolOptions{MaxOpen: 3})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := p.Do(context.Background(), "INCR", "counter"); err != nil {
+					t.Errorf("Do(INCR) returned error %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	reply, err := p.Do(context.Background(), "GET", "counter")
+	if err != nil || reply.Bulk != "800" {
+		t.Errorf("counter = %+v, %v, want 800", reply, err)
+	}
+	if stats := p.Stats(); stats.TotalConns > 3 || stats.Misses > 3 {
+		t.Errorf("Stats() = %+v, MaxOpen is 3", stats)
+	}
+}
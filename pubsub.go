@@ -0,0 +1,442 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPubSubConnLost is returned by Ping when the connection of the PubSub failed before the reply came or is being restored,
+// the PubSub reconnects and resubscribes on its own so the Ping can be retried
+var ErrPubSubConnLost = errors.New("goresp: pubsub connection lost")
+
+// Message is a message published on a channel the PubSub is subscribed to
+type Message struct {
+	Kind    string // "message", "pmessage" for a pattern subscription or "smessage" for a shard channel
+	Channel string
+	Pattern string // the pattern that matched the channel, only set for a pmessage
+	Payload string
+}
+
+// ParseMessage decodes the array, or the RESP3 push, a server sends for a published message
+// it reports false for any other value, like the confirmations of SUBSCRIBE
+func ParseMessage(v Value) (Message, bool) {
+	if v.Typ != KindArray && v.Typ != KindPush {
+		return Message{}, false
+	}
+
+	fields := make([]string, len(v.Array))
+	for i, elem := range v.Array {
+		s, err := elem.AsString()
+		if err != nil {
+			return Message{}, false
+		}
+		fields[i] = s
+	}
+
+	switch {
+	case len(fields) == 3 && (strings.EqualFold(fields[0], "message") || strings.EqualFold(fields[0], "smessage")):
+		return Message{Kind: strings.ToLower(fields[0]), Channel: fields[1], Payload: fields[2]}, true
+	case len(fields) == 4 && strings.EqualFold(fields[0], "pmessage"):
+		return Message{Kind: "pmessage", Pattern: fields[1], Channel: fields[2], Payload: fields[3]}, true
+	}
+	return Message{}, false
+}
+
+// PubSubOptions configure a PubSub
+type PubSubOptions struct {
+	Client ClientOptions // only the Reader limits apply
+
+	// Dial replaces the dialing of network and address, it is also used to reconnect
+	Dial func(ctx context.Context) (net.Conn, error)
+
+	ChannelSize int           // the messages buffered in the channel, 0 means 100
+	HealthCheck time.Duration // how often to PING the server, a PING without a reply in time resets the connection, 0 never pings
+}
+
+// PubSub consumes SUBSCRIBE, PSUBSCRIBE and SSUBSCRIBE traffic on a connection of its own
+// the messages are delivered on Channel, the subscriptions are restored when the connection is reestablished
+type PubSub struct {
+	opts PubSubOptions
+	dial func(ctx context.Context) (net.Conn, error)
+	msgs chan Message
+
+	ctx    context.Context // cancelled by Close, it stops the reconnections
+	cancel context.CancelFunc
+
+	cmdMu sync.Mutex // one command waits for its replies at a time
+
+	mu       sync.Mutex
+	conn     net.Conn // nil while the connection is being restored
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	shards   map[string]struct{}
+	waiters  []*pubsubWaiter // the commands waiting for replies, in the order they were written
+	closed   bool
+}
+
+// collects the replies of a command, the reader fills the first waiter of the queue
+type pubsubWaiter struct {
+	replies chan Value    // buffered to the number of replies expected
+	pending int           // the replies still to come, guarded by the mu of the PubSub
+	failed  chan struct{} // closed when the connection fails before all the replies came
+}
+
+func newPubSubWaiter(expected int) *pubsubWaiter {
+	return &pubsubWaiter{replies: make(chan Value, expected), pending: expected, failed: make(chan struct{})}
+}
+
+// NewPubSub connects to a server for pub/sub, network is "tcp" or "unix" like for Dial
+func NewPubSub(ctx context.Context, network, address string) (*PubSub, error) {
+	return NewPubSubWithOptions(ctx, network, address, PubSubOptions{})
+}
+
+func NewPubSubWithOptions(ctx context.Context, network, address string, opts PubSubOptions) (*PubSub, error) {
+	if opts.ChannelSize <= 0 {
+		opts.ChannelSize = 100
+	}
+	if opts.Client.Reader == (ReaderOptions{}) {
+		opts.Client.Reader = DefaultReaderOptions()
+	}
+
+	ps := &PubSub{
+		opts:     opts,
+		dial:     opts.Dial,
+		msgs:     make(chan Message, opts.ChannelSize),
+		channels: map[string]struct{}{},
+		patterns: map[string]struct{}{},
+		shards:   map[string]struct{}{},
+	}
+	if ps.dial == nil {
+		ps.dial = func(ctx context.Context) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+
+	conn, err := ps.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ps.conn = conn
+	ps.ctx, ps.cancel = context.WithCancel(context.Background())
+
+	go ps.run(conn)
+	if opts.HealthCheck > 0 {
+		go ps.healthCheck()
+	}
+
+	return ps, nil
+}
+
+// Channel returns the channel the messages are delivered on, it is closed by Close
+// the messages are read from the connection only as fast as they are received from the channel
+func (ps *PubSub) Channel() <-chan Message {
+	return ps.msgs
+}
+
+// Subscribe subscribes to channels and waits for the server to confirm
+func (ps *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	return ps.subscription(ctx, "SUBSCRIBE", ps.channels, true, channels)
+}
+
+// PSubscribe subscribes to the channels matching glob patterns
+func (ps *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	return ps.subscription(ctx, "PSUBSCRIBE", ps.patterns, true, patterns)
+}
+
+// SSubscribe subscribes to shard channels
+func (ps *PubSub) SSubscribe(ctx context.Context, channels ...string) error {
+	return ps.subscription(ctx, "SSUBSCRIBE", ps.shards, true, channels)
+}
+
+// Unsubscribe unsubscribes from channels, from all of them when none is given
+func (ps *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	return ps.subscription(ctx, "UNSUBSCRIBE", ps.channels, false, channels)
+}
+
+// PUnsubscribe unsubscribes from patterns, from all of them when none is given
+func (ps *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	return ps.subscription(ctx, "PUNSUBSCRIBE", ps.patterns, false, patterns)
+}
+
+// SUnsubscribe unsubscribes from shard channels, from all of them when none is given
+func (ps *PubSub) SUnsubscribe(ctx context.Context, channels ...string) error {
+	return ps.subscription(ctx, "SUNSUBSCRIBE", ps.shards, false, channels)
+}
+
+// Ping sends a PING and waits for the pong, a server replies it as an array while the connection is subscribed
+// it returns ErrPubSubConnLost while the connection is down
+func (ps *PubSub) Ping(ctx context.Context) error {
+	ps.cmdMu.Lock()
+	defer ps.cmdMu.Unlock()
+
+	ps.mu.Lock()
+	if ps.closed {
+		ps.mu.Unlock()
+		return ErrClosed
+	}
+	replies, err := ps.exchange(ctx, []any{"PING"}, 1)
+	if err != nil {
+		return err
+	}
+
+	reply := replies[0]
+	if err := reply.Err(); err != nil {
+		return err
+	}
+	if pong, _ := reply.AsString(); strings.EqualFold(pong, "PONG") {
+		return nil
+	}
+	if (reply.Typ == KindArray || reply.Typ == KindPush) && len(reply.Array) > 0 {
+		if pong, _ := reply.Array[0].AsString(); strings.EqualFold(pong, "pong") {
+			return nil
+		}
+	}
+	return &ReplyError{Message: "unexpected reply to PING: " + FormatReply(reply)}
+}
+
+// Close closes the connection and the channel of the messages
+func (ps *PubSub) Close() error {
+	ps.mu.Lock()
+	if ps.closed {
+		ps.mu.Unlock()
+		return nil
+	}
+	ps.closed = true
+	ps.cancel()
+	conn := ps.conn
+	ps.conn = nil
+	ps.mu.Unlock()
+
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// updates the subscriptions and sends the command, a connection that fails meanwhile is restored with the new subscriptions
+func (ps *PubSub) subscription(ctx context.Context, name string, set map[string]struct{}, subscribe bool, names []string) error {
+	ps.cmdMu.Lock()
+	defer ps.cmdMu.Unlock()
+
+	ps.mu.Lock()
+	if ps.closed {
+		ps.mu.Unlock()
+		return ErrClosed
+	}
+
+	// one confirmation comes per name, unsubscribing from everything confirms every subscription or a single nil one
+	expected := max(len(names), 1)
+	if !subscribe && len(names) == 0 {
+		expected = max(len(set), 1)
+		clear(set)
+	}
+	for _, n := range names {
+		if subscribe {
+			set[n] = struct{}{}
+		} else {
+			delete(set, n)
+		}
+	}
+	if ps.conn == nil {
+		ps.mu.Unlock()
+		return nil
+	}
+
+	args := make([]any, 0, len(names)+1)
+	args = append(args, name)
+	for _, n := range names {
+		args = append(args, n)
+	}
+	replies, err := ps.exchange(ctx, args, expected)
+	if errors.Is(err, ErrPubSubConnLost) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, reply := range replies {
+		if err := reply.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writes a command and waits for its replies, it is called with cmdMu and mu held and releases mu
+// when ctx ends the connection is reset, the replies can't be told apart from the ones of the next command otherwise
+func (ps *PubSub) exchange(ctx context.Context, args []any, expected int) ([]Value, error) {
+	conn := ps.conn
+	if conn == nil {
+		ps.mu.Unlock()
+		return nil, ErrPubSubConnLost
+	}
+	buf, err := appendCommand(nil, args)
+	if err != nil {
+		ps.mu.Unlock()
+		return nil, err
+	}
+	w := newPubSubWaiter(expected)
+	ps.waiters = append(ps.waiters, w)
+	ps.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := conn.Write(buf); err != nil {
+		conn.Close()
+	}
+
+	replies := make([]Value, 0, expected)
+	for len(replies) < expected {
+		select {
+		case v := <-w.replies:
+			replies = append(replies, v)
+		case <-w.failed:
+			// the replies that came before the failure are still buffered
+			if len(w.replies) > 0 {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return nil, ErrPubSubConnLost
+		}
+	}
+	return replies, nil
+}
+
+// reads the connection and restores it when it fails, until Close
+func (ps *PubSub) run(conn net.Conn) {
+	defer close(ps.msgs)
+
+	for conn != nil {
+		ps.receive(conn)
+		conn = ps.reconnect()
+	}
+}
+
+// delivers the messages and hands the other replies to the waiting command, it returns once the connection failed
+func (ps *PubSub) receive(conn net.Conn) {
+	reader := NewRespIoWithOptions(conn, ps.opts.Client.Reader)
+	defer func() {
+		conn.Close()
+		ps.mu.Lock()
+		if ps.conn == conn {
+			ps.conn = nil
+		}
+		waiters := ps.waiters
+		ps.waiters = nil
+		ps.mu.Unlock()
+
+		for _, w := range waiters {
+			close(w.failed)
+		}
+	}()
+
+	for {
+		v, err := reader.Read()
+		if err != nil {
+			return
+		}
+
+		if msg, ok := ParseMessage(v); ok {
+			select {
+			case ps.msgs <- msg:
+			case <-ps.ctx.Done():
+				return
+			}
+			continue
+		}
+
+		// a reply nobody waits for, like a server side SUNSUBSCRIBE, is dropped
+		ps.mu.Lock()
+		if len(ps.waiters) > 0 {
+			w := ps.waiters[0]
+			w.replies <- v
+			if w.pending--; w.pending == 0 {
+				ps.waiters = ps.waiters[1:]
+			}
+		}
+		ps.mu.Unlock()
+	}
+}
+
+// dials until a connection is up again and resubscribes it, it returns nil once the PubSub is closed
+func (ps *PubSub) reconnect() net.Conn {
+	var backoff time.Duration
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-ps.ctx.Done():
+			return nil
+		}
+		backoff = min(max(2*backoff, 10*time.Millisecond), time.Second)
+
+		conn, err := ps.dial(ps.ctx)
+		if err != nil {
+			continue
+		}
+
+		ps.mu.Lock()
+		if ps.closed {
+			ps.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+
+		// the confirmations are collected by a waiter nobody reads so they aren't taken for the replies of the next command
+		var buf []byte
+		replies := 0
+		for _, sub := range []struct {
+			name string
+			set  map[string]struct{}
+		}{{"SUBSCRIBE", ps.channels}, {"PSUBSCRIBE", ps.patterns}, {"SSUBSCRIBE", ps.shards}} {
+			if len(sub.set) == 0 {
+				continue
+			}
+			args := []any{sub.name}
+			for n := range sub.set {
+				args = append(args, n)
+			}
+			buf, _ = appendCommand(buf, args)
+			replies += len(sub.set)
+		}
+		if replies > 0 {
+			ps.waiters = append(ps.waiters, newPubSubWaiter(replies))
+		}
+		_, err = conn.Write(buf)
+		if err != nil {
+			ps.waiters = nil
+			ps.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		ps.conn = conn
+		ps.mu.Unlock()
+
+		return conn
+	}
+}
+
+// pings the server every HealthCheck, a PING that doesn't get its reply in time resets the connection
+func (ps *PubSub) healthCheck() {
+	ticker := time.NewTicker(ps.opts.HealthCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(ps.ctx, ps.opts.HealthCheck)
+			ps.Ping(ctx)
+			cancel()
+		case <-ps.ctx.Done():
+			return
+		}
+	}
+}
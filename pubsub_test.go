@@ -0,0 +1,449 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBroker is an in-process server that speaks the RESP2 pub/sub protocol
+type fakeBroker struct {
+	listener net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]*fakeSubscriber
+	wg    sync.WaitGroup
+}
+
+type fakeSubscriber struct {
+	channels, patterns, shards map[string]bool
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() returned error %v", err)
+	}
+
+	b := &fakeBroker{listener: listener, conns: map[net.Conn]*fakeSubscriber{}}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns[conn] = &fakeSubscriber{channels: map[string]bool{}, patterns: map[string]bool{}, shards: map[string]bool{}}
+			b.mu.Unlock()
+			b.wg.Add(1)
+			go b.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		b.dropConns()
+		b.wg.Wait()
+	})
+
+	return b
+}
+
+func (b *fakeBroker) dial(ctx context.Context) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", b.listener.Addr().String())
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer b.wg.Done()
+	reader := NewRespIo(conn)
+	for {
+		v, err := reader.Read()
+		if err != nil {
+			return
+		}
+		args, _ := commandArgs(v)
+
+		b.mu.Lock()
+		sub := b.conns[conn]
+		if sub == nil {
+			b.mu.Unlock()
+			return
+		}
+		var out []byte
+		confirm := func(kind string, name Value, set map[string]bool) {
+			count := len(sub.channels) + len(sub.patterns)
+			if set != nil && kind[0] == 's' {
+				count = len(sub.shards)
+			}
+			confirmation := Value{Typ: KindArray, Array: []Value{{Typ: KindBulk, Bulk: kind}, name, NewNumberValue(int64(count))}}
+			out = confirmation.AppendMarshal(out)
+		}
+		switch name := strings.ToLower(args[0]); name {
+		case "subscribe", "psubscribe", "ssubscribe":
+			if len(args) == 1 {
+				out = AppendError(out, "ERR wrong number of arguments for '"+name+"' command")
+				break
+			}
+			set := map[string]map[string]bool{"subscribe": sub.channels, "psubscribe": sub.patterns, "ssubscribe": sub.shards}[name]
+			for _, ch := range args[1:] {
+				set[ch] = true
+				confirm(name, Value{Typ: KindBulk, Bulk: ch}, set)
+			}
+		case "unsubscribe", "punsubscribe", "sunsubscribe":
+			set := map[string]map[string]bool{"unsubscribe": sub.channels, "punsubscribe": sub.patterns, "sunsubscribe": sub.shards}[name]
+			names := args[1:]
+			if len(names) == 0 {
+				for ch := range set {
+					names = append(names, ch)
+				}
+			}
+			if len(names) == 0 {
+				confirm(name, Value{Typ: KindNull}, set)
+			}
+			for _, ch := range names {
+				delete(set, ch)
+				confirm(name, Value{Typ: KindBulk, Bulk: ch}, set)
+			}
+		case "ping":
+			if len(sub.channels)+len(sub.patterns)+len(sub.shards) > 0 {
+				out = NewCommandValue("pong", "").AppendMarshal(out)
+			} else {
+				out = AppendSimpleString(out, "PONG")
+			}
+		default:
+			out = AppendError(out, "ERR only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+		}
+		conn.Write(out)
+		b.mu.Unlock()
+	}
+}
+
+// sends a message to the subscribers of channel, shard reaches the SSUBSCRIBE ones instead
+func (b *fakeBroker) publish(channel, payload string, shard bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn, sub := range b.conns {
+		var out []byte
+		if shard {
+			if sub.shards[channel] {
+				out = NewCommandValue("smessage", channel, payload).AppendMarshal(out)
+			}
+		} else {
+			if sub.channels[channel] {
+				out = NewCommandValue("message", channel, payload).AppendMarshal(out)
+			}
+			for pattern := range sub.patterns {
+				if ok, _ := path.Match(pattern, channel); ok {
+					out = NewCommandValue("pmessage", pattern, channel, payload).AppendMarshal(out)
+				}
+			}
+		}
+		conn.Write(out)
+	}
+}
+
+func (b *fakeBroker) dropConns() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn := range b.conns {
+		conn.Close()
+		delete(b.conns, conn)
+	}
+}
+
+func newTestPubSub(t *testing.T, b *fakeBroker, opts PubSubOptions) *PubSub {
+	t.Helper()
+	if opts.Dial == nil {
+		opts.Dial = b.dial
+	}
+	ps, err := NewPubSubWithOptions(context.Background(), "", "", opts)
+	if err != nil {
+		t.Fatalf("NewPubSub() returned error %v", err)
+	}
+	t.Cleanup(func() { ps.Close() })
+	return ps
+}
+
+func receive(t *testing.T, ps *PubSub) Message {
+	t.Helper()
+	select {
+	case msg := <-ps.Channel():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message was received")
+		return Message{}
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		input Value
+		want  Message
+		ok    bool
+	}{
+		{
+			NewCommandValue("message", "news", "hi"),
+			Message{Kind: "message", Channel: "news", Payload: "hi"}, true,
+		},
+		{
+			NewPushValue(NewCommandValue("pmessage", "n*", "news", "hi").Array),
+			Message{Kind: "pmessage", Pattern: "n*", Channel: "news", Payload: "hi"}, true,
+		},
+		{
+			NewPushValue(NewCommandValue("smessage", "shard", "").Array),
+			Message{Kind: "smessage", Channel: "shard"}, true,
+		},
+		{Value{Typ: KindArray, Array: []Value{{Typ: KindBulk, Bulk: "subscribe"}, {Typ: KindBulk, Bulk: "news"}, NewNumberValue(1)}}, Message{}, false},
+		{NewCommandValue("message", "news"), Message{}, false},
+		{Value{Typ: KindBulk, Bulk: "message"}, Message{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseMessage(tt.input)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseMessage(%s) = %+v, %v, want %+v, %v", FormatReply(tt.input), got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPubSub_Subscribe(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+	ctx := context.Background()
+
+	if err := ps.Subscribe(ctx, "news", "sport"); err != nil {
+		t.Fatalf("Subscribe() returned error %v", err)
+	}
+	if err := ps.PSubscribe(ctx, "log.*"); err != nil {
+		t.Fatalf("PSubscribe() returned error %v", err)
+	}
+	if err := ps.SSubscribe(ctx, "orders"); err != nil {
+		t.Fatalf("SSubscribe() returned error %v", err)
+	}
+
+	b.publish("sport", "goal", false)
+	b.publish("log.error", "disk full", false)
+	b.publish("orders", "#1", true)
+
+	want := []Message{
+		{Kind: "message", Channel: "sport", Payload: "goal"},
+		{Kind: "pmessage", Pattern: "log.*", Channel: "log.error", Payload: "disk full"},
+		{Kind: "smessage", Channel: "orders", Payload: "#1"},
+	}
+	for _, w := range want {
+		if got := receive(t, ps); got != w {
+			t.Errorf("received %+v, want %+v", got, w)
+		}
+	}
+}
+
+func TestPubSub_Unsubscribe(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+	ctx := context.Background()
+
+	ps.Subscribe(ctx, "a", "b", "c")
+	if err := ps.Unsubscribe(ctx, "a"); err != nil {
+		t.Fatalf("Unsubscribe(a) returned error %v", err)
+	}
+	b.publish("a", "dropped", false)
+	b.publish("b", "kept", false)
+	if got := receive(t, ps); got.Channel != "b" {
+		t.Errorf("received %+v, a was unsubscribed", got)
+	}
+
+	// unsubscribing from everything waits for the confirmation of b and c
+	if err := ps.Unsubscribe(ctx); err != nil {
+		t.Fatalf("Unsubscribe() returned error %v", err)
+	}
+	if len(ps.channels) != 0 {
+		t.Errorf("the channels %v are left after Unsubscribe()", ps.channels)
+	}
+	// and with no subscription left a single confirmation comes
+	if err := ps.PUnsubscribe(ctx); err != nil {
+		t.Fatalf("PUnsubscribe() returned error %v", err)
+	}
+	if err := ps.Ping(ctx); err != nil {
+		t.Errorf("Ping() after the unsubscriptions returned %v, the replies are out of step", err)
+	}
+}
+
+func TestPubSub_Ping(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+	ctx := context.Background()
+
+	if err := ps.Ping(ctx); err != nil {
+		t.Fatalf("Ping() returned error %v", err)
+	}
+	ps.Subscribe(ctx, "news")
+	// while subscribed the pong is an array
+	if err := ps.Ping(ctx); err != nil {
+		t.Fatalf("Ping() while subscribed returned error %v", err)
+	}
+
+	b.publish("news", "after ping", false)
+	if got := receive(t, ps); got.Payload != "after ping" {
+		t.Errorf("received %+v", got)
+	}
+}
+
+func TestPubSub_Resubscribes(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+	ctx := context.Background()
+
+	ps.Subscribe(ctx, "news")
+	ps.PSubscribe(ctx, "log.*")
+	ps.SSubscribe(ctx, "orders")
+	b.dropConns()
+
+	// publish until the new connection has resubscribed
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.publish("news", "back", false)
+		select {
+		case msg := <-ps.Channel():
+			if msg.Payload != "back" {
+				t.Fatalf("received %+v", msg)
+			}
+		case <-time.After(10 * time.Millisecond):
+			if time.Now().After(deadline) {
+				t.Fatal("the subscription wasn't restored")
+			}
+			continue
+		}
+		break
+	}
+
+	b.publish("log.x", "p", false)
+	b.publish("orders", "s", true)
+	for _, kind := range []string{"pmessage", "smessage"} {
+		got := receive(t, ps)
+		for got.Payload == "back" {
+			got = receive(t, ps)
+		}
+		if got.Kind != kind {
+			t.Errorf("received %+v, want a %s", got, kind)
+		}
+	}
+	if err := ps.Ping(ctx); err != nil {
+		t.Errorf("Ping() after the reconnection returned %v", err)
+	}
+}
+
+func TestPubSub_Ping_ConnLost(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+	ctx := context.Background()
+
+	// the server goes away for good, the PubSub keeps trying to reconnect
+	b.listener.Close()
+	b.dropConns()
+
+	if err := ps.Ping(ctx); !errors.Is(err, ErrPubSubConnLost) {
+		t.Errorf("Ping() without a connection returned %v, want ErrPubSubConnLost", err)
+	}
+}
+
+func TestPubSub_HealthCheck(t *testing.T) {
+	b := newFakeBroker(t)
+	var dials atomic.Int32
+	ps := newTestPubSub(t, b, PubSubOptions{
+		HealthCheck: 20 * time.Millisecond,
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			dials.Add(1)
+			return b.dial(ctx)
+		},
+	})
+	ps.Subscribe(context.Background(), "news")
+
+	// a server that stops answering is found out by the PING and the connection is reset
+	b.mu.Lock()
+	for conn := range b.conns {
+		delete(b.conns, conn)
+	}
+	b.mu.Unlock()
+
+	for deadline := time.Now().Add(time.Second); dials.Load() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("the silent connection was never replaced")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPubSub_Subscribe_ReplyError(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+
+	var replyErr *ReplyError
+	if err := ps.Subscribe(context.Background()); !errors.As(err, &replyErr) {
+		t.Errorf("Subscribe() without channels returned %v, want the error reply", err)
+	}
+	if err := ps.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() after the error reply returned %v", err)
+	}
+}
+
+func TestPubSub_Close(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+	ps.Subscribe(context.Background(), "news")
+
+	if err := ps.Close(); err != nil {
+		t.Fatalf("Close() returned error %v", err)
+	}
+	select {
+	case _, ok := <-ps.Channel():
+		if ok {
+			t.Error("received a message after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close() didn't close the channel")
+	}
+	if err := ps.Subscribe(context.Background(), "x"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe() after Close returned %v, want ErrClosed", err)
+	}
+	if err := ps.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Ping() after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestPubSub_Subscribe_ContextEnds(t *testing.T) {
+	b := newFakeBroker(t)
+	ps := newTestPubSub(t, b, PubSubOptions{})
+	ps.Subscribe(context.Background(), "news")
+
+	// the server stops answering, the subscription is still recorded and restored later
+	b.mu.Lock()
+	for conn := range b.conns {
+		delete(b.conns, conn)
+	}
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := ps.Subscribe(ctx, "sport"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Subscribe() returned %v, want context.DeadlineExceeded", err)
+	}
+	want := map[string]struct{}{"news": {}, "sport": {}}
+	ps.mu.Lock()
+	got := maps.Clone(ps.channels)
+	ps.mu.Unlock()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the channels are %v, want %v", got, want)
+	}
+}
@@ -1,46 +1,173 @@
 package goresp
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 )
 
+// ErrUnbalancedQuotes is returned by SplitArgs for a quote that is never closed or that is not followed by a space
+var ErrUnbalancedQuotes = errors.New("goresp: unbalanced quotes in request")
+
 // serializeCommand converts the given string to RESP formated bytes
+// the arguments are split with the redis quoting rules of SplitArgs, a line with unbalanced quotes is split on spaces instead
 func SerializeCommand(command string) []byte {
-	parts := strings.Fields(command)
+	parts, err := SplitArgs(command)
+	if err != nil {
+		parts = strings.Fields(command)
+	}
 
 	var test []Value
 
 	for _, part := range parts {
 
-		bulk := Value{Typ: "bulk", Bulk: part}
+		bulk := Value{Typ: KindBulk, Bulk: part}
 		test = append(test, bulk)
 	}
 
-	newCommand := Value{Typ: "array", Array: test}
+	newCommand := Value{Typ: KindArray, Array: test}
 
 	return newCommand.Marshal()
 }
 
+// SerializeCommandArgs is like SerializeCommand but returns the error of SplitArgs instead of falling back to spaces
+func SerializeCommandArgs(command string) ([]byte, error) {
+	parts, err := SplitArgs(command)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCommandValue(parts...).Marshal(), nil
+}
+
+// SplitArgs splits a command line into arguments like redis-cli and sdssplitargs do:
+//   - arguments are separated by spaces
+//   - "double quoted" arguments understand \n \r \t \b \a \xHH and escaped characters like \" or \\
+//   - 'single quoted' arguments are taken as is except for \'
+//   - a closing quote must be followed by a space or the end of the line
+func SplitArgs(line string) ([]string, error) {
+	args := []string{}
+	i := 0
+	for {
+		// skip blanks
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var current []byte
+		inDouble, inSingle := false, false
+		for done := false; !done; {
+			if inDouble {
+				switch {
+				case i == len(line):
+					return nil, ErrUnbalancedQuotes
+				case line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]):
+					current = append(current, hexValue(line[i+2])*16+hexValue(line[i+3]))
+					i += 3
+				case line[i] == '\\' && i+1 < len(line):
+					i++
+					switch line[i] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[i])
+					}
+				case line[i] == '"':
+					// closing quote must be followed by a space or nothing at all
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, line[i])
+				}
+			} else if inSingle {
+				switch {
+				case i == len(line):
+					return nil, ErrUnbalancedQuotes
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					current = append(current, '\'')
+				case line[i] == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current = append(current, line[i])
+				}
+			} else {
+				switch {
+				case i == len(line) || isSpace(line[i]):
+					done = true
+				case line[i] == '"':
+					inDouble = true
+				case line[i] == '\'':
+					inSingle = true
+				default:
+					current = append(current, line[i])
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+
+		args = append(args, string(current))
+	}
+}
+
+// the blanks of the C isspace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\v' || c == '\f'
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
 // SerializeValue takes resp Value and converts it to string
+// use FormatReply or FormatReplyRaw for the human readable output of redis-cli
 func SerializeValue(res Value) string {
 	//Todo: handle null and error
 	switch res.Typ {
-	case "bulk":
+	case KindBulk:
 		{
 			return res.Bulk
 		}
-	case "string":
+	case KindString:
 		{
 			return res.Str
 		}
 
-	case "int":
+	case KindInt:
 		{
 			return strconv.Itoa(int(res.Num))
 		}
 
-	case "array":
+	case KindArray:
 		{
 			var fullStr string
 			for _, value := range res.Array {
@@ -1,6 +1,9 @@
 package goresp
 
 import (
+	"bytes"
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -58,6 +61,89 @@ func TestSerializeCommandWithUnicodeCharacters(t *testing.T) {
 	}
 }
 
+func TestSerializeCommandWithQuotes(t *testing.T) {
+	command := `SET greeting "hello world"`
+	expected := "*3\r\n$3\r\nSET\r\n$8\r\ngreeting\r\n$11\r\nhello world\r\n"
+
+	result := SerializeCommand(command)
+
+	if string(result) != expected {
+		t.Errorf("SerializeCommand(%q) = %q, want %q", command, string(result), expected)
+	}
+}
+
+func TestSerializeCommandUnbalancedQuotesFallsBack(t *testing.T) {
+	command := `SET key "value`
+	expected := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$6\r\n\"value\r\n"
+
+	result := SerializeCommand(command)
+
+	if string(result) != expected {
+		t.Errorf("SerializeCommand(%q) = %q, want %q", command, string(result), expected)
+	}
+}
+
+func TestSerializeCommandArgs(t *testing.T) {
+	result, err := SerializeCommandArgs(`SET key "\x00\xff"`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$2\r\n\x00\xff\r\n"
+	if string(result) != expected {
+		t.Errorf("SerializeCommandArgs() = %q, want %q", result, expected)
+	}
+
+	if _, err := SerializeCommandArgs(`SET key 'value`); !errors.Is(err, ErrUnbalancedQuotes) {
+		t.Errorf("Expected ErrUnbalancedQuotes, got %v", err)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		line     string
+		expected []string
+	}{
+		{"Empty", "", []string{}},
+		{"Blanks", " \t ", []string{}},
+		{"Words", "SET key value", []string{"SET", "key", "value"}},
+		{"ExtraSpaces", "  SET   key\tvalue  ", []string{"SET", "key", "value"}},
+		{"DoubleQuotes", `SET k "hello world"`, []string{"SET", "k", "hello world"}},
+		{"SingleQuotes", `SET k 'hello world'`, []string{"SET", "k", "hello world"}},
+		{"EmptyQuotes", `SET k ""`, []string{"SET", "k", ""}},
+		{"Escapes", `"a\nb\rc\td\be\af"`, []string{"a\nb\rc\td\be\af"}},
+		{"EscapedQuote", `"say \"hi\""`, []string{`say "hi"`}},
+		{"EscapedBackslash", `"a\\b"`, []string{`a\b`}},
+		{"Hex", `"\x41\x6a\xFF"`, []string{"Aj\xff"}},
+		{"InvalidHex", `"\xZZ"`, []string{"xZZ"}},
+		{"SingleQuoteKeepsBackslash", `'a\nb'`, []string{`a\nb`}},
+		{"SingleQuoteEscapedQuote", `'it\'s'`, []string{"it's"}},
+		{"QuoteInsideWord", `key"with space"`, []string{"keywith space"}},
+		{"Unicode", "SET 你好 '世界'", []string{"SET", "你好", "世界"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := SplitArgs(tc.line)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("SplitArgs(%q) = %q, want %q", tc.line, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSplitArgsErrors(t *testing.T) {
+	for _, line := range []string{`"unterminated`, `'unterminated`, `"closed"text`, `'closed'text`, `"ends with backslash\`} {
+		if _, err := SplitArgs(line); !errors.Is(err, ErrUnbalancedQuotes) {
+			t.Errorf("SplitArgs(%q): expected ErrUnbalancedQuotes, got %v", line, err)
+		}
+	}
+}
+
 // SerializeValue Test
 
 func TestSerializeValueBulk(t *testing.T) {
@@ -170,3 +256,36 @@ func TestSerializeValueVeryLongString(t *testing.T) {
 		t.Errorf("SerializeValue(%v) = %q (length: %d), want %q (length: %d)", input, result[:100], len(result), expected[:100], len(expected))
 	}
 }
+
+func FuzzSerializeCommand(f *testing.F) {
+	for _, seed := range []string{
+		"PING", "SET key value", `SET "key with space" 'it''s'`, `SET k "\x41\n\t\"q\""`, `GET "unbalanced`,
+		`GET "a"b`, "  spaced   out  ", "", `\xzz "\xzz"`, "SET k 'a\\'b'", "日本語 ключ",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, command string) {
+		expected, err := SplitArgs(command)
+		if err != nil {
+			expected = strings.Fields(command)
+		}
+
+		wire := SerializeCommand(command)
+		result, err := NewRespIo(strings.NewReader(string(wire))).Read()
+		if err != nil {
+			t.Fatalf("Read() of %q returned error %v", wire, err)
+		}
+		if result.Typ != KindArray || len(result.Array) != len(expected) {
+			t.Fatalf("SerializeCommand(%q) = %q, want %d arguments", command, wire, len(expected))
+		}
+		for i, arg := range result.Array {
+			if arg.Typ != KindBulk || arg.Bulk != expected[i] {
+				t.Fatalf("argument %d of SerializeCommand(%q) = %+v, want %q", i, command, arg, expected[i])
+			}
+		}
+		if got := result.Marshal(); !bytes.Equal(got, wire) {
+			t.Fatalf("Marshal() after Read() = %q, want %q", got, wire)
+		}
+	})
+}
@@ -0,0 +1,504 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after a call to Shutdown or Close
+var ErrServerClosed = errors.New("goresp: server closed")
+
+// Server accepts RESP connections and answers their commands with Handler, like http.Server
+// each connection is served by a goroutine of its own, the fields must not be changed once the server is serving
+type Server struct {
+	Network string  // "tcp" or "unix" for ListenAndServe, "" means "tcp"
+	Addr    string  // the address ListenAndServe listens on, "" means ":6379"
+	Handler Handler // usually a *ServeMux, nil replies an unknown command error to everything
+
+	// Reader are the limits applied to the commands of the clients, DefaultReaderOptions with Inline when it is the zero value
+	Reader ReaderOptions
+
+	MaxClients  int           // the most connections served at once, the others are replied an error and closed, 0 means no limit
+	IdleTimeout time.Duration // a connection that sends no command for this long is closed, like the timeout of redis, 0 means never
+
+	ErrorLog *log.Logger // where the panics of handlers are logged, nil uses the log package
+
+	inShutdown atomic.Bool
+	nextID     atomic.Int64
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]struct{}
+}
+
+// Conn is a client connection of a Server, handlers reach it through Request.Conn
+type Conn struct {
+	server *Server
+	conn   net.Conn
+	id     int64
+	reader *RespIo
+	reply  replyBuffer // the replies of the commands read so far, written once the pipeline is drained
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex // orders the read deadlines of the loop and of Shutdown, guards onClose
+	onClose []func()
+
+	noIdleTimeout atomic.Bool // set for the connections IdleTimeout doesn't apply to, like the subscribed ones
+
+	// the output is written by a goroutine of its own so other goroutines, like a pub/sub broker, can send to the connection
+	omu     sync.Mutex
+	out     []byte        // the bytes waiting to be written
+	writing int           // the bytes being written
+	outDone bool          // no more output is accepted
+	wake    chan struct{} // signals the writer that out has bytes or that outDone was set
+	drained chan struct{} // signals the serve loop that a write completed
+	written chan struct{} // closed once the writer has exited
+}
+
+// the most replies a connection lets pile up before it stops reading commands,
+// a client that pipelines without reading its replies is held back like a blocking write would
+const maxPendingReplies = 64 * 1024
+
+// ID returns the identifier of the connection, unique within the server like CLIENT ID
+func (c *Conn) ID() int64 {
+	return c.id
+}
+
+// RemoteAddr returns the address of the client
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close closes the connection, the command being served still completes but its reply is lost
+func (c *Conn) Close() error {
+	c.cancel()
+	return c.conn.Close()
+}
+
+// OnClose registers f to run once the connection is closed and its last command was served,
+// handlers use it to drop the state they keep for the connection
+func (c *Conn) OnClose(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onClose = append(c.onClose, f)
+}
+
+// ListenAndServe listens on s.Network and s.Addr and serves the connections, it always returns a non nil error
+func (s *Server) ListenAndServe() error {
+	if s.shuttingDown() {
+		return ErrServerClosed
+	}
+
+	network, addr := s.Network, s.Addr
+	if network == "" {
+		network = "tcp"
+	}
+	if addr == "" {
+		addr = ":6379"
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until it fails or the server is shut down, it closes l before returning
+// after Shutdown or Close it returns ErrServerClosed
+func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrackListener(l)
+
+	var delay time.Duration // how long to wait before the next Accept after a temporary error
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				// like net/http, back off when the process runs out of file descriptors
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		if c := s.newConn(nc); c != nil {
+			go c.serve()
+		}
+	}
+}
+
+// Shutdown stops the server gracefully: it closes the listeners, lets the commands being served finish and write their replies,
+// then closes the connections, the idle ones at once
+// when ctx ends first the remaining connections are closed and ctx.Err() is returned
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.inShutdown.Store(true)
+
+	s.mu.Lock()
+	err := s.closeListeners()
+	for c := range s.conns {
+		c.interrupt()
+	}
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		remaining := len(s.conns)
+		s.mu.Unlock()
+		if remaining == 0 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			s.closeConns()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the listeners and every connection at once, the commands being served lose their replies
+// use Shutdown to let them finish
+func (s *Server) Close() error {
+	s.inShutdown.Store(true)
+
+	s.mu.Lock()
+	err := s.closeListeners()
+	s.mu.Unlock()
+	s.closeConns()
+
+	return err
+}
+
+func (s *Server) shuttingDown() bool {
+	return s.inShutdown.Load()
+}
+
+func (s *Server) trackListener(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown() {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = map[net.Listener]struct{}{}
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackListener(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.listeners[l]; ok {
+		delete(s.listeners, l)
+		l.Close()
+	}
+}
+
+// closes the listeners, the caller holds s.mu
+func (s *Server) closeListeners() error {
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	clear(s.listeners)
+	return err
+}
+
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// registers an accepted connection, it returns nil when the connection is turned down
+func (s *Server) newConn(nc net.Conn) *Conn {
+	s.mu.Lock()
+	if s.shuttingDown() {
+		s.mu.Unlock()
+		nc.Close()
+		return nil
+	}
+	if s.MaxClients > 0 && len(s.conns) >= s.MaxClients {
+		s.mu.Unlock()
+		// the reply redis sends before it closes the connection, written without s.mu,
+		// a client that doesn't read it can only stall the accept loop for the write deadline
+		nc.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+		nc.Write(AppendError(nil, "ERR max number of clients reached"))
+		nc.Close()
+		return nil
+	}
+	defer s.mu.Unlock()
+
+	opts := s.Reader
+	if opts == (ReaderOptions{}) {
+		opts = DefaultReaderOptions()
+		opts.Inline = true
+	}
+
+	c := &Conn{
+		server:  s,
+		conn:    nc,
+		id:      s.nextID.Add(1),
+		reader:  NewRespIoWithOptions(nc, opts),
+		wake:    make(chan struct{}, 1),
+		drained: make(chan struct{}, 1),
+		written: make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	if s.conns == nil {
+		s.conns = map[*Conn]struct{}{}
+	}
+	s.conns[c] = struct{}{}
+
+	return c
+}
+
+// the loop of a connection: read a command, serve it, and write the replies once no more commands are waiting
+func (c *Conn) serve() {
+	replied := 0 // the length of the replies before the command being served
+	defer func() {
+		if err := recover(); err != nil {
+			buf := make([]byte, 64<<10)
+			buf = buf[:runtime.Stack(buf, false)]
+			c.server.logf("goresp: panic serving %v: %v\n%s", c.RemoteAddr(), err, buf)
+			// the handler may have written half a reply, the replies of the commands before it are still sent
+			c.reply.buf = c.reply.buf[:replied]
+		}
+		// the replies still queued are written before the connection closes
+		c.flush()
+		c.omu.Lock()
+		c.outDone = true
+		c.omu.Unlock()
+		c.signal()
+		<-c.written
+		c.Close()
+
+		c.mu.Lock()
+		onClose := c.onClose
+		c.mu.Unlock()
+		for _, f := range onClose {
+			f()
+		}
+
+		c.server.mu.Lock()
+		delete(c.server.conns, c)
+		c.server.mu.Unlock()
+	}()
+	go c.writeLoop()
+
+	handler := c.server.Handler
+	if handler == nil {
+		handler = NewServeMux()
+	}
+
+	for {
+		if !c.prepareRead() {
+			return
+		}
+
+		v, err := c.reader.Read()
+		if err != nil {
+			// a truncated command is a client that went away, the other protocol errors are replied like redis does
+			var protoErr *ProtocolError
+			if errors.As(err, &protoErr) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				c.reply.WriteError("ERR Protocol error: " + err.Error())
+			}
+			return
+		}
+
+		args, ok := commandArgs(v)
+		if !ok {
+			c.reply.WriteError("ERR Protocol error: expected a command as an array of bulk strings")
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		replied = len(c.reply.buf)
+		handler.ServeRESP(&c.reply, &Request{Args: args, Conn: c, ctx: c.ctx})
+
+		if c.reader.buffered() == 0 || len(c.reply.buf) >= maxPendingReplies {
+			c.flush()
+			if !c.waitOutput() {
+				return
+			}
+		}
+	}
+}
+
+// sets the deadline of the next read, it reports false when the server is shutting down and the connection must close
+func (c *Conn) prepareRead() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.server.shuttingDown() {
+		return false
+	}
+
+	var deadline time.Time
+	if c.server.IdleTimeout > 0 && !c.noIdleTimeout.Load() {
+		deadline = time.Now().Add(c.server.IdleTimeout)
+	}
+	c.conn.SetReadDeadline(deadline)
+	return true
+}
+
+// wakes up a connection waiting for its next command, one that is serving a command finishes it first
+func (c *Conn) interrupt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetReadDeadline(aLongTimeAgo)
+}
+
+// hands the buffered replies to the writer
+func (c *Conn) flush() {
+	c.enqueue(c.reply.buf, 0)
+	c.reply.buf = c.reply.buf[:0]
+}
+
+// queues b for the writer, limit is the most bytes the output may hold with b, 0 means no limit
+// a connection over the limit is closed, it reports whether b was queued
+func (c *Conn) enqueue(b []byte, limit int) bool {
+	if len(b) == 0 {
+		return true
+	}
+
+	c.omu.Lock()
+	if c.outDone {
+		c.omu.Unlock()
+		return false
+	}
+	if limit > 0 && len(c.out)+c.writing+len(b) > limit {
+		c.outDone = true
+		c.omu.Unlock()
+		c.Close()
+		return false
+	}
+	c.out = append(c.out, b...)
+	c.omu.Unlock()
+
+	c.signal()
+	return true
+}
+
+// waits until the client has read enough of its output, it reports false when no more output will be written
+func (c *Conn) waitOutput() bool {
+	for {
+		c.omu.Lock()
+		pending, done := len(c.out)+c.writing, c.outDone
+		c.omu.Unlock()
+		if done {
+			return false
+		}
+		if pending <= maxPendingReplies {
+			return true
+		}
+
+		select {
+		case <-c.drained:
+		case <-c.ctx.Done():
+			return false
+		}
+	}
+}
+
+func (c *Conn) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// writes the output as it is queued, until it is done and drained or a write fails
+func (c *Conn) writeLoop() {
+	defer close(c.written)
+
+	var buf []byte
+	for {
+		c.omu.Lock()
+		buf, c.out = c.out, buf[:0]
+		c.writing = len(buf)
+		done := c.outDone
+		c.omu.Unlock()
+
+		if len(buf) == 0 {
+			if done {
+				return
+			}
+			<-c.wake
+			continue
+		}
+
+		if _, err := c.conn.Write(buf); err != nil {
+			c.omu.Lock()
+			c.outDone, c.out, c.writing = true, nil, 0
+			c.omu.Unlock()
+			c.Close()
+			return
+		}
+
+		c.omu.Lock()
+		c.writing = 0
+		c.omu.Unlock()
+		select {
+		case c.drained <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// the arguments of a command, the clients send it as an array of bulk strings
+func commandArgs(v Value) ([]string, bool) {
+	if v.Typ != KindArray {
+		return nil, false
+	}
+
+	args := make([]string, len(v.Array))
+	for i, arg := range v.Array {
+		if arg.Typ != KindBulk {
+			return nil, false
+		}
+		args[i] = arg.Bulk
+	}
+	return args, true
+}
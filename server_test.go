@@ -0,0 +1,453 @@
+package goresp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// starts s on a local port and returns its address, the server is closed at the end of the test
+func startServer(t *testing.T, s *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() returned error %v", err)
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-served; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve() returned %v, want ErrServerClosed", err)
+		}
+	})
+
+	return l.Addr().String()
+}
+
+func newTestServerMux() *ServeMux {
+	return newTestServerMuxWithTouch(nil)
+}
+
+// like newTestServerMux, touch is called with the key of every SET, a transaction engine watches the keys with it
+func newTestServerMuxWithTouch(touch func(keys ...string)) *ServeMux {
+	var mu sync.Mutex
+	data := map[string]string{}
+
+	mux := NewServeMux()
+	mux.HandleFunc("PING", -1, 0, func(w ReplyWriter, r *Request) {
+		w.WriteSimpleString("PONG")
+	})
+	mux.HandleFunc("SET", 3, FlagWrite, func(w ReplyWriter, r *Request) {
+		mu.Lock()
+		data[r.Args[1]] = r.Args[2]
+		mu.Unlock()
+		if touch != nil {
+			touch(r.Args[1])
+		}
+		w.WriteSimpleString("OK")
+	})
+	mux.HandleFunc("GET", 2, FlagReadonly, func(w ReplyWriter, r *Request) {
+		mu.Lock()
+		v, ok := data[r.Args[1]]
+		mu.Unlock()
+		if !ok {
+			w.WriteNull()
+			return
+		}
+		w.WriteBulk(v)
+	})
+	mux.HandleFunc("CLIENT ID", 2, 0, func(w ReplyWriter, r *Request) {
+		w.WriteInt(r.Conn.ID())
+	})
+	return mux
+}
+
+func TestServer_Serve(t *testing.T) {
+	addr := startServer(t, &Server{Handler: newTestServerMux()})
+	client, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	if reply, err := client.Do(ctx, "SET", "k", "v"); err != nil || reply.Str != "OK" {
+		t.Fatalf("Do(SET) = %+v, %v", reply, err)
+	}
+	if reply, err := client.Do(ctx, "get", "k"); err != nil || reply.Bulk != "v" {
+		t.Fatalf("Do(GET) = %+v, %v", reply, err)
+	}
+	if reply, err := client.Do(ctx, "CLIENT", "ID"); err != nil || reply.Num != 1 {
+		t.Errorf("Do(CLIENT ID) = %+v, %v, want the first connection", reply, err)
+	}
+	var replyErr *ReplyError
+	if _, err := client.Do(ctx, "GET"); !errors.As(err, &replyErr) || !strings.Contains(replyErr.Message, "wrong number of arguments") {
+		t.Errorf("Do(GET) without a key returned %v", err)
+	}
+
+	// a pipeline is answered in order
+	pipe := client.Pipeline()
+	for i := 0; i < 100; i++ {
+		pipe.Queue("PING")
+	}
+	cmds, err := pipe.Exec(ctx)
+	if err != nil || len(cmds) != 100 || cmds[99].Reply.Str != "PONG" {
+		t.Errorf("Exec() of 100 PING returned %v", err)
+	}
+}
+
+func TestServer_Serve_Inline(t *testing.T) {
+	addr := startServer(t, &Server{Handler: newTestServerMux()})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("SET greeting \"hello world\"\r\n\r\nGET greeting\n"))
+
+	reader := NewRespIo(conn)
+	for _, want := range []Value{{Typ: KindString, Str: "OK"}, {Typ: KindBulk, Bulk: "hello world"}} {
+		if got, err := reader.Read(); err != nil || got.Typ != want.Typ || got.Str != want.Str || got.Bulk != want.Bulk {
+			t.Errorf("Read() = %+v, %v, want %+v", got, err, want)
+		}
+	}
+}
+
+func TestServer_Serve_ProtocolError(t *testing.T) {
+	addr := startServer(t, &Server{Handler: newTestServerMux()})
+
+	tests := []string{
+		"*1\r\n$abc\r\n",
+		"*1\r\n:1\r\n",
+		"SET \"unbalanced\r\n",
+	}
+	for _, input := range tests {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial() returned error %v", err)
+		}
+		conn.Write([]byte(input))
+
+		got, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil || !bytes.HasPrefix(got, []byte("-ERR Protocol error: ")) {
+			t.Errorf("%q was replied %q (%v), want a protocol error and the connection closed", input, got, err)
+		}
+	}
+}
+
+// a client that pipelines commands without reading the replies stops being read from once its output piles up
+func TestServer_Serve_Backpressure(t *testing.T) {
+	var served atomic.Int64
+	reply := strings.Repeat("x", 1024)
+	addr := startServer(t, &Server{Handler: HandlerFunc(func(w ReplyWriter, r *Request) {
+		served.Add(1)
+		w.WriteBulk(reply)
+	})})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer conn.Close()
+
+	// 64MB of replies, far more than the socket buffers hold
+	const total = 64 * 1024
+	go func() {
+		cmds := bytes.Repeat(NewCommandValue("BIG").Marshal(), 1024)
+		for range total / 1024 {
+			if _, err := conn.Write(cmds); err != nil {
+				return
+			}
+		}
+	}()
+
+	// the server stalls, waiting for the client to read
+	last := int64(-1)
+	for deadline := time.Now().Add(5 * time.Second); served.Load() != last && time.Now().Before(deadline); {
+		last = served.Load()
+		time.Sleep(100 * time.Millisecond)
+	}
+	if last >= total {
+		t.Fatalf("served all %d commands while the client read nothing", last)
+	}
+
+	// and catches up once the client reads
+	reader := NewRespIo(conn)
+	for i := range total {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if v, err := reader.Read(); err != nil || v.Bulk != reply {
+			t.Fatalf("reply %d = %d bytes, %v", i, len(v.Bulk), err)
+		}
+	}
+}
+
+func TestServer_MaxClients(t *testing.T) {
+	addr := startServer(t, &Server{Handler: newTestServerMux(), MaxClients: 1})
+
+	first, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	if _, err := first.Do(context.Background(), "PING"); err != nil {
+		t.Fatalf("Do(PING) returned error %v", err)
+	}
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	got, _ := io.ReadAll(second)
+	second.Close()
+	if string(got) != "-ERR max number of clients reached\r\n" {
+		t.Errorf("the connection above MaxClients was replied %q", got)
+	}
+
+	// the slot is freed once the first client leaves
+	first.Close()
+	for deadline := time.Now().Add(time.Second); ; {
+		third, err := Dial(context.Background(), "tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial() returned error %v", err)
+		}
+		_, err = third.Do(context.Background(), "PING")
+		third.Close()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Do(PING) after the first client left returned %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	addr := startServer(t, &Server{Handler: newTestServerMux(), IdleTimeout: 30 * time.Millisecond})
+	start := time.Now()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Fatalf("Read() = %d, %v, want the server to close the idle connection", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("the connection was closed after %v, before IdleTimeout", elapsed)
+	}
+}
+
+func TestServer_Shutdown_DrainsCommands(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := newTestServerMux()
+	mux.HandleFunc("SLOW", 1, 0, func(w ReplyWriter, r *Request) {
+		close(started)
+		<-release
+		w.WriteSimpleString("DONE")
+	})
+	s := &Server{Handler: mux}
+	addr := startServer(t, s)
+
+	busy, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer busy.Close()
+	idle, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer idle.Close()
+	if _, err := idle.Do(context.Background(), "PING"); err != nil {
+		t.Fatalf("Do(PING) returned error %v", err)
+	}
+
+	slow := make(chan Value)
+	go func() {
+		reply, _ := busy.Do(context.Background(), "SLOW")
+		slow <- reply
+	}()
+	<-started
+
+	shutdown := make(chan error)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown() returned %v while a command was running", err)
+	case <-time.After(30 * time.Millisecond):
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("the server accepted a connection after Shutdown")
+	}
+	if _, err := idle.Do(context.Background(), "PING"); err == nil {
+		t.Error("the idle connection should be closed by Shutdown")
+	}
+
+	close(release)
+	if reply := <-slow; reply.Str != "DONE" {
+		t.Errorf("the running command was replied %+v, want DONE", reply)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown() returned %v", err)
+	}
+}
+
+func TestServer_Shutdown_ContextEnds(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	mux := NewServeMux()
+	mux.HandleFunc("STUCK", 1, 0, func(w ReplyWriter, r *Request) {
+		close(started)
+		<-release
+	})
+	s := &Server{Handler: mux}
+	addr := startServer(t, s)
+
+	client, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer client.Close()
+	failed := make(chan error)
+	go func() {
+		_, err := client.Do(context.Background(), "STUCK")
+		failed <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() returned %v, want context.DeadlineExceeded", err)
+	}
+	if err := <-failed; err == nil {
+		t.Error("the stuck command succeeded, want its connection closed")
+	}
+}
+
+// a bytes.Buffer the server can log to while the test reads it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServer_Serve_HandlerPanic(t *testing.T) {
+	var logged lockedBuffer
+	mux := newTestServerMux()
+	mux.HandleFunc("BOOM", 1, 0, func(w ReplyWriter, r *Request) {
+		w.WriteArrayHeader(2)
+		panic("boom")
+	})
+	addr := startServer(t, &Server{Handler: mux, ErrorLog: log.New(&logged, "", 0)})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	conn.Write(NewCommandValue("BOOM").Marshal())
+	got, _ := io.ReadAll(bufio.NewReader(conn))
+	conn.Close()
+	if len(got) != 0 {
+		t.Errorf("the panicking command was replied %q, want the connection closed", got)
+	}
+	if !strings.Contains(logged.String(), "panic serving") {
+		t.Errorf("the panic wasn't logged, the log is %q", logged.String())
+	}
+
+	// the commands pipelined before the panicking one keep their replies
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	conn.Write(append(NewCommandValue("PING").Marshal(), NewCommandValue("BOOM").Marshal()...))
+	got, _ = io.ReadAll(bufio.NewReader(conn))
+	conn.Close()
+	if string(got) != "+PONG\r\n" {
+		t.Errorf("PING then BOOM was replied %q, want only the PONG", got)
+	}
+
+	client, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer client.Close()
+	if _, err := client.Do(context.Background(), "PING"); err != nil {
+		t.Errorf("Do(PING) after a panic returned %v, the server should keep serving", err)
+	}
+}
+
+func TestServer_Request_Context(t *testing.T) {
+	done := make(chan error, 1)
+	mux := NewServeMux()
+	mux.HandleFunc("WAIT", 1, 0, func(w ReplyWriter, r *Request) {
+		go func() {
+			<-r.Context().Done()
+			done <- r.Context().Err()
+		}()
+		w.WriteSimpleString("OK")
+	})
+	addr := startServer(t, &Server{Handler: mux})
+
+	client, err := Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	if _, err := client.Do(context.Background(), "WAIT"); err != nil {
+		t.Fatalf("Do(WAIT) returned error %v", err)
+	}
+	client.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("the request context ended with %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the request context wasn't cancelled when the connection closed")
+	}
+}
+
+func TestServer_Serve_AfterShutdown(t *testing.T) {
+	s := &Server{}
+	s.Shutdown(context.Background())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() returned error %v", err)
+	}
+	if err := s.Serve(l); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve() after Shutdown returned %v, want ErrServerClosed", err)
+	}
+	if err := s.ListenAndServe(); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("ListenAndServe() after Shutdown returned %v, want ErrServerClosed", err)
+	}
+}
@@ -0,0 +1,270 @@
+package goresp
+
+import (
+	"strings"
+	"sync"
+)
+
+// Transactions implements MULTI, EXEC, DISCARD, WATCH and UNWATCH for a Server, with the replies and errors of redis
+//
+// Wrap serves every command under a shared lock and EXEC runs the queued commands under the exclusive one,
+// so no other command runs in the middle of a transaction, the storage layer calls Touch when it modifies keys
+// so the transactions watching them fail
+type Transactions struct {
+	mux   *ServeMux    // checks and runs the queued commands, set by Register
+	store sync.RWMutex // held exclusively by EXEC
+
+	mu      sync.Mutex
+	states  map[*Conn]*txState
+	watched map[string]map[*Conn]struct{}
+}
+
+// the transaction of a connection
+type txState struct {
+	multi   bool       // MULTI was received, the commands are queued until EXEC or DISCARD
+	aborted bool       // a command was refused while queueing, EXEC replies EXECABORT
+	queue   []*Request // the queued commands
+	watched map[string]struct{}
+	dirty   bool // a watched key was modified, EXEC replies a null array
+}
+
+// NewTransactions creates the transaction state of a server, it has to be registered on the ServeMux serving the commands
+func NewTransactions() *Transactions {
+	return &Transactions{
+		states:  map[*Conn]*txState{},
+		watched: map[string]map[*Conn]struct{}{},
+	}
+}
+
+// Register adds the transaction commands to mux, with the arities of redis,
+// the commands queued after MULTI are checked against mux and EXEC runs them through it
+func (t *Transactions) Register(mux *ServeMux) {
+	t.mux = mux
+	mux.HandleFunc("MULTI", 1, 0, t.serveMulti)
+	mux.HandleFunc("EXEC", 1, 0, t.serveExec)
+	mux.HandleFunc("DISCARD", 1, 0, t.serveDiscard)
+	mux.HandleFunc("WATCH", -2, 0, t.serveWatch)
+	mux.HandleFunc("UNWATCH", 1, 0, t.serveUnwatch)
+}
+
+// Wrap queues the commands of a connection inside MULTI and replies QUEUED, a command mux doesn't know or
+// that has the wrong number of arguments is replied its error and makes EXEC fail with EXECABORT,
+// the other commands go through to next under the shared lock, next is usually the ServeMux given to Register
+func (t *Transactions) Wrap(next Handler) Handler {
+	return HandlerFunc(func(w ReplyWriter, r *Request) {
+		if len(r.Args) == 0 {
+			next.ServeRESP(w, r)
+			return
+		}
+
+		name := strings.ToLower(r.Args[0])
+		if r.Conn != nil && t.inMulti(r.Conn) {
+			switch name {
+			case "exec", "discard", "multi", "watch", "quit", "reset":
+			default:
+				t.enqueue(w, r)
+				return
+			}
+		}
+
+		// EXEC takes the exclusive lock itself
+		if name == "exec" {
+			next.ServeRESP(w, r)
+			return
+		}
+		t.store.RLock()
+		defer t.store.RUnlock()
+		next.ServeRESP(w, r)
+	})
+}
+
+// Touch is called by the storage layer when it modifies keys, the transactions watching one of them will fail
+// it is meant to be called by the handlers, it doesn't take the lock of Wrap
+func (t *Transactions) Touch(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range keys {
+		for c := range t.watched[key] {
+			t.states[c].dirty = true
+		}
+	}
+}
+
+// TouchAll fails every transaction watching a key, for commands like FLUSHALL that modify the whole store
+func (t *Transactions) TouchAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, state := range t.states {
+		if len(state.watched) > 0 {
+			state.dirty = true
+		}
+	}
+}
+
+func (t *Transactions) serveMulti(w ReplyWriter, r *Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.state(r.Conn)
+	if state == nil {
+		w.WriteError("ERR MULTI needs a connection")
+		return
+	}
+	if state.multi {
+		w.WriteError("ERR MULTI calls can not be nested")
+		return
+	}
+	state.multi = true
+	w.WriteSimpleString("OK")
+}
+
+func (t *Transactions) serveExec(w ReplyWriter, r *Request) {
+	// the watched keys are checked and the commands run without any other command in between
+	t.store.Lock()
+	defer t.store.Unlock()
+
+	t.mu.Lock()
+	state := t.state(r.Conn)
+	if state == nil || !state.multi {
+		t.mu.Unlock()
+		w.WriteError("ERR EXEC without MULTI")
+		return
+	}
+	queue, aborted, dirty := state.queue, state.aborted, state.dirty
+	t.reset(r.Conn, state)
+	t.mu.Unlock()
+
+	switch {
+	case aborted:
+		w.WriteError("EXECABORT Transaction discarded because of previous errors.")
+	case dirty:
+		w.WriteValue(NewNullArrayValue())
+	default:
+		// every queued command is expected to write one reply, the element of the EXEC array
+		w.WriteArrayHeader(len(queue))
+		for _, queued := range queue {
+			t.mux.ServeRESP(w, queued)
+		}
+	}
+}
+
+func (t *Transactions) serveDiscard(w ReplyWriter, r *Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.state(r.Conn)
+	if state == nil || !state.multi {
+		w.WriteError("ERR DISCARD without MULTI")
+		return
+	}
+	t.reset(r.Conn, state)
+	w.WriteSimpleString("OK")
+}
+
+func (t *Transactions) serveWatch(w ReplyWriter, r *Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.state(r.Conn)
+	if state == nil {
+		w.WriteError("ERR WATCH needs a connection")
+		return
+	}
+	if state.multi {
+		w.WriteError("ERR WATCH inside MULTI is not allowed")
+		return
+	}
+	for _, key := range r.Args[1:] {
+		if _, ok := state.watched[key]; ok {
+			continue
+		}
+		state.watched[key] = struct{}{}
+		if t.watched[key] == nil {
+			t.watched[key] = map[*Conn]struct{}{}
+		}
+		t.watched[key][r.Conn] = struct{}{}
+	}
+	w.WriteSimpleString("OK")
+}
+
+func (t *Transactions) serveUnwatch(w ReplyWriter, r *Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if state := t.states[r.Conn]; state != nil {
+		t.unwatch(r.Conn, state)
+	}
+	w.WriteSimpleString("OK")
+}
+
+// queues a command of a connection inside MULTI, or replies the error mux would and aborts the transaction
+func (t *Transactions) enqueue(w ReplyWriter, r *Request) {
+	cmd := t.mux.Lookup(r.Args)
+	valid := cmd != nil && cmd.Handler != nil && cmd.acceptsArgs(len(r.Args))
+
+	t.mu.Lock()
+	state := t.states[r.Conn]
+	if valid {
+		state.queue = append(state.queue, r)
+	} else {
+		state.aborted = true
+	}
+	t.mu.Unlock()
+
+	if !valid {
+		// replies the error without running anything
+		t.mux.ServeRESP(w, r)
+		return
+	}
+	w.WriteSimpleString("QUEUED")
+}
+
+func (t *Transactions) inMulti(c *Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.states[c]
+	return state != nil && state.multi
+}
+
+// returns the transaction of a connection, created on first use, or nil for a request without a connection
+// t.mu must be held
+func (t *Transactions) state(c *Conn) *txState {
+	if c == nil {
+		return nil
+	}
+	state := t.states[c]
+	if state == nil {
+		state = &txState{watched: map[string]struct{}{}}
+		t.states[c] = state
+		c.OnClose(func() { t.drop(c) })
+	}
+	return state
+}
+
+// ends the transaction of a connection and unwatches its keys, t.mu must be held
+func (t *Transactions) reset(c *Conn, state *txState) {
+	state.multi, state.aborted, state.queue = false, false, nil
+	t.unwatch(c, state)
+}
+
+// t.mu must be held
+func (t *Transactions) unwatch(c *Conn, state *txState) {
+	for key := range state.watched {
+		unindex(t.watched, key, c)
+	}
+	clear(state.watched)
+	state.dirty = false
+}
+
+// removes the state of a closed connection
+func (t *Transactions) drop(c *Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if state := t.states[c]; state != nil {
+		t.unwatch(c, state)
+		delete(t.states, c)
+	}
+}
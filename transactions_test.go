@@ -0,0 +1,154 @@
+package goresp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serves the store of newTestServerMux, it reports the keys SET modifies to tx
+func startTxServer(t *testing.T, tx *Transactions) string {
+	t.Helper()
+	mux := newTestServerMuxWithTouch(tx.Touch)
+	tx.Register(mux)
+	return startServer(t, &Server{Handler: tx.Wrap(mux)})
+}
+
+func TestTransactions_Exec(t *testing.T) {
+	conn := dialRaw(t, startTxServer(t, NewTransactions()))
+
+	exchangeRaw(t, conn, "-ERR EXEC without MULTI\r\n", "EXEC")
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "-ERR MULTI calls can not be nested\r\n", "MULTI")
+	exchangeRaw(t, conn, "-ERR WATCH inside MULTI is not allowed\r\n", "WATCH", "k")
+	exchangeRaw(t, conn, "+QUEUED\r\n", "SET", "k", "v")
+	exchangeRaw(t, conn, "+QUEUED\r\n", "get", "k")
+	exchangeRaw(t, conn, "*2\r\n+OK\r\n$1\r\nv\r\n", "EXEC")
+
+	// DISCARD drops the queued commands
+	exchangeRaw(t, conn, "-ERR DISCARD without MULTI\r\n", "DISCARD")
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "+QUEUED\r\n", "SET", "k", "discarded")
+	exchangeRaw(t, conn, "+OK\r\n", "DISCARD")
+	exchangeRaw(t, conn, "$1\r\nv\r\n", "GET", "k")
+
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "*0\r\n", "EXEC")
+}
+
+func TestTransactions_ExecAbort(t *testing.T) {
+	conn := dialRaw(t, startTxServer(t, NewTransactions()))
+
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "+QUEUED\r\n", "SET", "k", "v")
+	exchangeRaw(t, conn, "-ERR unknown command 'NOPE', with args beginning with: 'a' \r\n", "NOPE", "a")
+	exchangeRaw(t, conn, "-ERR wrong number of arguments for 'get' command\r\n", "GET")
+	exchangeRaw(t, conn, "-EXECABORT Transaction discarded because of previous errors.\r\n", "EXEC")
+
+	// nothing ran and the connection left the transaction
+	exchangeRaw(t, conn, "$-1\r\n", "GET", "k")
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "*0\r\n", "EXEC")
+}
+
+func TestTransactions_Watch(t *testing.T) {
+	tx := NewTransactions()
+	addr := startTxServer(t, tx)
+	conn, other := dialRaw(t, addr), dialRaw(t, addr)
+
+	// a watched key modified by another connection fails the transaction
+	exchangeRaw(t, conn, "+OK\r\n", "WATCH", "k", "unrelated")
+	exchangeRaw(t, other, "+OK\r\n", "SET", "k", "theirs")
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "+QUEUED\r\n", "SET", "k", "mine")
+	exchangeRaw(t, conn, "*-1\r\n", "EXEC")
+	exchangeRaw(t, conn, "$6\r\ntheirs\r\n", "GET", "k")
+
+	// EXEC unwatched the keys, the next transaction isn't affected
+	exchangeRaw(t, other, "+OK\r\n", "SET", "k", "again")
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "+QUEUED\r\n", "SET", "k", "mine")
+	exchangeRaw(t, conn, "*1\r\n+OK\r\n", "EXEC")
+
+	// so does UNWATCH
+	exchangeRaw(t, conn, "+OK\r\n", "WATCH", "k")
+	exchangeRaw(t, conn, "+OK\r\n", "UNWATCH")
+	exchangeRaw(t, other, "+OK\r\n", "SET", "k", "again")
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "*0\r\n", "EXEC")
+
+	// keys modified by the connection itself count too, and TouchAll fails every watcher
+	exchangeRaw(t, conn, "+OK\r\n", "WATCH", "k")
+	exchangeRaw(t, conn, "+OK\r\n", "SET", "k", "own")
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "*-1\r\n", "EXEC")
+	exchangeRaw(t, conn, "+OK\r\n", "WATCH", "other")
+	tx.TouchAll()
+	exchangeRaw(t, conn, "+OK\r\n", "MULTI")
+	exchangeRaw(t, conn, "*-1\r\n", "EXEC")
+}
+
+func TestTransactions_ConnClosed(t *testing.T) {
+	tx := NewTransactions()
+	conn := dialRaw(t, startTxServer(t, tx))
+
+	exchangeRaw(t, conn, "+OK\r\n", "WATCH", "k")
+	conn.Close()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		tx.mu.Lock()
+		states, watched := len(tx.states), len(tx.watched)
+		tx.mu.Unlock()
+		if states == 0 && watched == 0 {
+			return
+		}
+	}
+	t.Error("the state of the closed connection was not removed")
+}
+
+// the transactions of the client retried by RunTx make concurrent increments atomic
+func TestTransactions_WithClientRunTx(t *testing.T) {
+	addr := startTxServer(t, NewTransactions())
+	ctx := context.Background()
+
+	const clients, increments = 4, 25
+	var wg sync.WaitGroup
+	for range clients {
+		client, err := Dial(ctx, "tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial() returned error %v", err)
+		}
+		t.Cleanup(func() { client.Close() })
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				_, err := client.RunTx(ctx, []string{"counter"}, 0, func(tx *Tx) error {
+					reply, err := tx.Do(ctx, "GET", "counter")
+					if err != nil {
+						return err
+					}
+					n, _ := reply.AsInt()
+					tx.Queue("SET", "counter", n+1)
+					return nil
+				})
+				if err != nil {
+					t.Errorf("RunTx() returned error %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	client, err := Dial(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() returned error %v", err)
+	}
+	defer client.Close()
+	if reply, err := client.Do(ctx, "GET", "counter"); err != nil || reply.Bulk != "100" {
+		t.Errorf("counter = %+v, %v, want 100", reply, err)
+	}
+}
@@ -0,0 +1,198 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTxConflict is returned by Tx.Exec when the server aborted the transaction because a watched key changed,
+// EXEC replied a null array and none of the commands ran
+var ErrTxConflict = errors.New("goresp: transaction aborted, a watched key changed")
+
+// Tx runs commands in a MULTI/EXEC transaction, the commands are queued locally and sent with MULTI and EXEC in a single write
+//
+// WATCH only protects the keys if nothing else runs on the connection between Watch and Exec,
+// so a Client shared between goroutines should use a connection of its own, for example one taken from a Pool
+type Tx struct {
+	client *Client
+	buf    []byte         // the encoded commands, without MULTI and EXEC
+	cmds   []*PipelineCmd // the queued commands
+	err    error          // the first command that failed to encode, the transaction can't be sent without it
+}
+
+// Tx returns an empty transaction that runs on the connection of c
+func (c *Client) Tx() *Tx {
+	return &Tx{client: c}
+}
+
+// Watch sends WATCH for keys, Exec fails with ErrTxConflict if one of them is modified before it
+// without keys nothing is sent, redis refuses a WATCH without arguments
+func (tx *Tx) Watch(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(keys)+1)
+	args = append(args, "WATCH")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	_, err := tx.client.Do(ctx, args...)
+	return err
+}
+
+// Unwatch forgets the watched keys
+func (tx *Tx) Unwatch(ctx context.Context) error {
+	_, err := tx.client.Do(ctx, "UNWATCH")
+	return err
+}
+
+// Do runs a command right away, outside of the transaction, it is meant to read the watched keys before queueing
+func (tx *Tx) Do(ctx context.Context, args ...any) (Value, error) {
+	return tx.client.Do(ctx, args...)
+}
+
+// Queue adds a command to the transaction, its arguments are encoded like for Client.Do
+// an argument that can't be encoded fails the whole transaction, Exec returns its error without sending anything
+func (tx *Tx) Queue(args ...any) *PipelineCmd {
+	cmd := &PipelineCmd{Args: args}
+	tx.cmds = append(tx.cmds, cmd)
+
+	buf, err := appendCommand(tx.buf, args)
+	if err != nil {
+		cmd.Err = err
+		if tx.err == nil {
+			tx.err = err
+		}
+		return cmd
+	}
+	tx.buf = buf
+
+	return cmd
+}
+
+// Len returns the number of queued commands
+func (tx *Tx) Len() int {
+	return len(tx.cmds)
+}
+
+// Exec sends MULTI, the queued commands and EXEC, checks that every command was acknowledged with QUEUED
+// and sets the results of the EXEC array on the commands, then empties the transaction so it can be reused
+//
+// an error reply inside the EXEC array is set on its command as a *ReplyError, the other commands still ran,
+// a command the server refused to queue gets its error and the transaction fails with the EXECABORT error,
+// ErrTxConflict is returned when a watched key changed, it returns the first error of the commands otherwise
+func (tx *Tx) Exec(ctx context.Context) ([]*PipelineCmd, error) {
+	cmds, buf, encodeErr := tx.cmds, tx.buf, tx.err
+	tx.cmds, tx.buf, tx.err = nil, buf[:0], nil
+
+	if encodeErr != nil {
+		return cmds, failCmds(cmds, encodeErr)
+	}
+
+	req, _ := appendCommand(nil, []any{"MULTI"})
+	req = append(req, buf...)
+	req, _ = appendCommand(req, []any{"EXEC"})
+
+	c := tx.client
+	c.mu.Lock()
+	var replies []Value
+	err := c.err
+	if err == nil {
+		replies, err = c.roundTrip(ctx, req, len(cmds)+2)
+	}
+	c.mu.Unlock()
+
+	// without the reply of EXEC there is no telling whether the transaction ran
+	if err != nil {
+		return cmds, failCmds(cmds, err)
+	}
+	if err := replies[0].Err(); err != nil {
+		return cmds, failCmds(cmds, err)
+	}
+
+	for i, cmd := range cmds {
+		ack := replies[i+1]
+		if err := ack.Err(); err != nil {
+			cmd.Err = err
+		} else if ack.Typ != KindString || ack.Str != "QUEUED" {
+			cmd.Err = fmt.Errorf("goresp: the command was acknowledged with %s instead of QUEUED", FormatReply(ack))
+		}
+	}
+
+	exec := replies[len(replies)-1]
+	switch {
+	case exec.IsNull():
+		return cmds, failCmds(cmds, ErrTxConflict)
+	case exec.IsError():
+		return cmds, failCmds(cmds, exec.Err())
+	case exec.Typ != KindArray || len(exec.Array) != len(cmds):
+		return cmds, failCmds(cmds, fmt.Errorf("goresp: EXEC replied %s for %d commands", FormatReply(exec), len(cmds)))
+	}
+
+	for i, cmd := range cmds {
+		cmd.Reply = exec.Array[i]
+		if cmd.Err == nil {
+			cmd.Err = cmd.Reply.Err()
+		}
+	}
+	for _, cmd := range cmds {
+		if cmd.Err != nil {
+			return cmds, cmd.Err
+		}
+	}
+	return cmds, nil
+}
+
+// sets err on the commands that have no error of their own and returns it
+func failCmds(cmds []*PipelineCmd, err error) error {
+	for _, cmd := range cmds {
+		if cmd.Err == nil {
+			cmd.Err = err
+		}
+	}
+	return err
+}
+
+// RunTx runs an optimistic transaction: it watches keys, calls fn to read them with tx.Do and queue the commands,
+// then runs them with Exec, when a watched key changed meanwhile it starts over, at most maxAttempts times
+// maxAttempts 0 retries until ctx ends, after the last attempt ErrTxConflict is returned
+//
+// an error returned by fn unwatches the keys and is returned as is, nothing is sent when fn queues no command
+func (c *Client) RunTx(ctx context.Context, keys []string, maxAttempts int, fn func(tx *Tx) error) ([]*PipelineCmd, error) {
+	for attempt := 1; ; attempt++ {
+		tx := c.Tx()
+		if err := tx.Watch(ctx, keys...); err != nil {
+			return nil, err
+		}
+		if err := fn(tx); err != nil {
+			tx.Unwatch(ctx)
+			return nil, err
+		}
+		if tx.Len() == 0 {
+			return nil, tx.Unwatch(ctx)
+		}
+
+		cmds, err := tx.Exec(ctx)
+		if !errors.Is(err, ErrTxConflict) {
+			return cmds, err
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			return cmds, err
+		}
+		if err := ctx.Err(); err != nil {
+			return cmds, err
+		}
+	}
+}
+
+// RunTx runs an optimistic transaction on a connection of the pool, see Client.RunTx
+func (p *Pool) RunTx(ctx context.Context, keys []string, maxAttempts int, fn func(tx *Tx) error) ([]*PipelineCmd, error) {
+	c, err := p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Put(c)
+
+	return c.RunTx(ctx, keys, maxAttempts, fn)
+}
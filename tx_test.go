@@ -0,0 +1,289 @@
+package goresp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// wraps newFakeStore with MULTI, EXEC, DISCARD and WATCH, the transaction state is shared so the tests use a single connection
+// touch writes a key like another client would
+func newFakeTxStore() (handle func(args []string) Value, touch func(key, value string)) {
+	store := newFakeStore()
+	arity := map[string]int{"PING": 1, "SET": 3, "GET": 2, "INCR": 2}
+
+	var mu sync.Mutex
+	versions := map[string]int{}
+	watched := map[string]int{}
+	var queue [][]string
+	inMulti, aborted := false, false
+
+	// runs a command on the store and bumps the version of the key it writes
+	run := func(args []string) Value {
+		if args[0] == "SET" || args[0] == "INCR" {
+			versions[args[1]]++
+		}
+		return store(args)
+	}
+
+	handle = func(args []string) Value {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch args[0] {
+		case "MULTI":
+			if inMulti {
+				return NewErrorValue("ERR MULTI calls can not be nested")
+			}
+			inMulti, aborted, queue = true, false, nil
+			return Value{Typ: KindString, Str: "OK"}
+		case "WATCH":
+			if len(args) < 2 {
+				return NewErrorValue("ERR wrong number of arguments for 'watch' command")
+			}
+			for _, key := range args[1:] {
+				watched[key] = versions[key]
+			}
+			return Value{Typ: KindString, Str: "OK"}
+		case "UNWATCH":
+			clear(watched)
+			return Value{Typ: KindString, Str: "OK"}
+		case "EXEC":
+			defer func() { inMulti, queue = false, nil; clear(watched) }()
+			if aborted {
+				return NewErrorValue("EXECABORT Transaction discarded because of previous errors.")
+			}
+			for key, version := range watched {
+				if versions[key] != version {
+					return NewNullArrayValue()
+				}
+			}
+			results := make([]Value, len(queue))
+			for i, cmd := range queue {
+				results[i] = run(cmd)
+			}
+			return Value{Typ: KindArray, Array: results}
+		}
+
+		if inMulti {
+			if n, ok := arity[args[0]]; !ok || n != len(args) {
+				aborted = true
+				return NewErrorValue("ERR unknown command")
+			}
+			queue = append(queue, args)
+			return Value{Typ: KindString, Str: "QUEUED"}
+		}
+		return run(args)
+	}
+
+	touch = func(key, value string) {
+		mu.Lock()
+		defer mu.Unlock()
+		run([]string{"SET", key, value})
+	}
+
+	return handle, touch
+}
+
+func TestTx_Exec(t *testing.T) {
+	handle, _ := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+	client.Do(ctx, "SET", "name", "goresp")
+
+	tx := client.Tx()
+	set := tx.Queue("SET", "n", 1)
+	incr := tx.Queue("INCR", "n")
+	bad := tx.Queue("INCR", "name")
+	get := tx.Queue("GET", "n")
+
+	cmds, err := tx.Exec(ctx)
+
+	var replyErr *ReplyError
+	if !errors.As(err, &replyErr) || len(cmds) != 4 {
+		t.Fatalf("Exec() = %v, %v, want the error reply embedded in the EXEC array", cmds, err)
+	}
+	if set.Err != nil || set.Reply.Str != "OK" {
+		t.Errorf("SET = %+v", set)
+	}
+	if incr.Err != nil || incr.Reply.Num != 2 {
+		t.Errorf("INCR = %+v, want 2", incr)
+	}
+	if !errors.As(bad.Err, &replyErr) || !bad.Reply.IsError() {
+		t.Errorf("INCR of a string = %+v, want its error reply", bad)
+	}
+	if get.Err != nil || get.Reply.Bulk != "2" {
+		t.Errorf("GET = %+v, want 2", get)
+	}
+	if tx.Len() != 0 {
+		t.Errorf("Len() after Exec = %d, want 0", tx.Len())
+	}
+
+	// the transaction is over, the connection runs commands as usual
+	if reply, err := client.Do(ctx, "GET", "n"); err != nil || reply.Bulk != "2" {
+		t.Errorf("Do(GET) after Exec = %+v, %v", reply, err)
+	}
+}
+
+func TestTx_Exec_Aborted(t *testing.T) {
+	handle, _ := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+
+	tx := client.Tx()
+	set := tx.Queue("SET", "k", "v")
+	unknown := tx.Queue("NOPE")
+
+	_, err := tx.Exec(ctx)
+
+	var replyErr *ReplyError
+	if !errors.As(err, &replyErr) || replyErr.Prefix() != "EXECABORT" {
+		t.Fatalf("Exec() returned %v, want EXECABORT", err)
+	}
+	if !errors.As(unknown.Err, &replyErr) || replyErr.Message != "ERR unknown command" {
+		t.Errorf("the refused command got %v, want the error of its QUEUED ack", unknown.Err)
+	}
+	if !errors.As(set.Err, &replyErr) || replyErr.Prefix() != "EXECABORT" {
+		t.Errorf("the queued command got %v, want EXECABORT", set.Err)
+	}
+	if reply, _ := client.Do(ctx, "GET", "k"); !reply.IsNull() {
+		t.Errorf("GET k = %+v, the aborted transaction must not run", reply)
+	}
+}
+
+func TestTx_Exec_WatchConflict(t *testing.T) {
+	handle, touch := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+
+	tx := client.Tx()
+	if err := tx.Watch(ctx, "balance"); err != nil {
+		t.Fatalf("Watch() returned error %v", err)
+	}
+	touch("balance", "100")
+	set := tx.Queue("SET", "balance", "50")
+
+	if _, err := tx.Exec(ctx); !errors.Is(err, ErrTxConflict) || !errors.Is(set.Err, ErrTxConflict) {
+		t.Fatalf("Exec() returned %v and the command %v, want ErrTxConflict", err, set.Err)
+	}
+	if reply, _ := client.Do(ctx, "GET", "balance"); reply.Bulk != "100" {
+		t.Errorf("balance = %+v, the conflicting transaction must not run", reply)
+	}
+}
+
+func TestTx_Exec_UnsupportedArgument(t *testing.T) {
+	handle, _ := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+
+	tx := client.Tx()
+	set := tx.Queue("SET", "k", "v")
+	tx.Queue("SET", "k", struct{}{})
+
+	var typeErr *UnsupportedTypeError
+	if _, err := tx.Exec(ctx); !errors.As(err, &typeErr) || !errors.As(set.Err, &typeErr) {
+		t.Fatalf("Exec() returned %v, want an UnsupportedTypeError for the whole transaction", err)
+	}
+	// nothing was sent, no MULTI is left open
+	if reply, err := client.Do(ctx, "GET", "k"); err != nil || !reply.IsNull() {
+		t.Errorf("Do(GET) = %+v, %v, want null", reply, err)
+	}
+}
+
+func TestClient_RunTx(t *testing.T) {
+	handle, touch := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+	client.Do(ctx, "SET", "counter", "10")
+
+	attempts := 0
+	cmds, err := client.RunTx(ctx, []string{"counter"}, 5, func(tx *Tx) error {
+		attempts++
+		reply, err := tx.Do(ctx, "GET", "counter")
+		if err != nil {
+			return err
+		}
+		n, _ := reply.AsInt()
+		// another client changes the counter during the first attempt
+		if attempts == 1 {
+			touch("counter", "20")
+		}
+		tx.Queue("SET", "counter", n*2)
+		return nil
+	})
+
+	if err != nil || len(cmds) != 1 || attempts != 2 {
+		t.Fatalf("RunTx() = %v, %v after %d attempts, want success on the second", cmds, err, attempts)
+	}
+	if reply, _ := client.Do(ctx, "GET", "counter"); reply.Bulk != "40" {
+		t.Errorf("counter = %+v, want 40", reply)
+	}
+}
+
+func TestClient_RunTx_NoKeys(t *testing.T) {
+	handle, _ := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+
+	cmds, err := client.RunTx(ctx, nil, 1, func(tx *Tx) error {
+		tx.Queue("SET", "k", "v")
+		return nil
+	})
+	if err != nil || len(cmds) != 1 || cmds[0].Reply.Str != "OK" {
+		t.Errorf("RunTx() without keys = %v, %v", cmds, err)
+	}
+}
+
+func TestClient_RunTx_GivesUp(t *testing.T) {
+	handle, touch := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+
+	attempts := 0
+	_, err := client.RunTx(ctx, []string{"k"}, 3, func(tx *Tx) error {
+		attempts++
+		touch("k", "busy")
+		tx.Queue("SET", "k", "mine")
+		return nil
+	})
+
+	if !errors.Is(err, ErrTxConflict) || attempts != 3 {
+		t.Errorf("RunTx() returned %v after %d attempts, want ErrTxConflict after 3", err, attempts)
+	}
+}
+
+func TestClient_RunTx_FnError(t *testing.T) {
+	handle, touch := newFakeTxStore()
+	client := dialFake(t, newFakeServer(t, "tcp", handle))
+	ctx := context.Background()
+
+	stop := errors.New("not enough funds")
+	if _, err := client.RunTx(ctx, []string{"k"}, 0, func(tx *Tx) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("RunTx() returned %v, want the error of fn", err)
+	}
+
+	// the keys were unwatched, a later transaction isn't affected by changes made meanwhile
+	touch("k", "changed")
+	tx := client.Tx()
+	tx.Queue("SET", "k", "v")
+	if _, err := tx.Exec(ctx); err != nil {
+		t.Errorf("Exec() after RunTx gave up returned %v", err)
+	}
+}
+
+func TestPool_RunTx(t *testing.T) {
+	handle, _ := newFakeTxStore()
+	p := newFakePool(t, newFakeServer(t, "tcp", handle), PoolOptions{})
+
+	cmds, err := p.RunTx(context.Background(), []string{"k"}, 1, func(tx *Tx) error {
+		tx.Queue("INCR", "k")
+		return nil
+	})
+	if err != nil || len(cmds) != 1 || cmds[0].Reply.Num != 1 {
+		t.Errorf("RunTx() = %v, %v", cmds, err)
+	}
+	if stats := p.Stats(); stats.IdleConns != 1 {
+		t.Errorf("Stats() = %+v, the connection should be back in the pool", stats)
+	}
+}
@@ -9,6 +9,44 @@ const (
 	BULK    = '$'
 	ARRAY   = '*'
 	NULL    = '_'
+
+	// RESP3 types
+	DOUBLE    = ','
+	BOOLEAN   = '#'
+	BIGNUMBER = '('
+	VERBATIM  = '='
+	BLOBERROR = '!'
+	MAP       = '%'
+	SET       = '~'
+	ATTRIBUTE = '|'
+	PUSH      = '>'
+)
+
+// Kind is the RESP type of a Value, it is a string so the old untyped literals like Value{Typ: "bulk"} keep working
+type Kind string
+
+const (
+	KindString    Kind = "string"
+	KindError     Kind = "error"
+	KindInt       Kind = "int"
+	KindBulk      Kind = "bulk"
+	KindArray     Kind = "array"
+	KindNull      Kind = "null"      // the null bulk string $-1 and the RESP3 null _
+	KindNullArray Kind = "nullarray" // the null array *-1
+
+	// RESP3 kinds
+	KindDouble    Kind = "double"
+	KindBoolean   Kind = "boolean"
+	KindBigNumber Kind = "bignumber"
+	KindVerbatim  Kind = "verbatim"
+	KindBlobError Kind = "bloberror"
+	KindMap       Kind = "map"
+	KindSet       Kind = "set"
+	KindPush      Kind = "push"
+
+	// Deprecated: older versions of RespIo read integers as "integer" while Marshal only knew "int",
+	// the readers now produce KindInt and Marshal still accepts KindInteger for values built by old code
+	KindInteger Kind = "integer"
 )
 
 type RespReader interface {
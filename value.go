@@ -2,15 +2,32 @@ package goresp
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 type Value struct {
-	Typ   string
+	Typ   Kind
 	Str   string
 	Num   int64
 	Bulk  string
 	Array []Value
+
+	// RESP3 fields
+	Double float64
+	Bool   bool
+	Format string     // the 3 bytes format of a verbatim string like "txt" or "mkd"
+	Map    []MapEntry // the ordered key value pairs of a map
+	Attrs  []MapEntry // the attributes sent ahead of this value with the '|' type
+
+	Resp3Null bool // the null was read as the RESP3 _ and not as $-1, it is marshalled back the same way
+}
+
+// MapEntry is a single key value pair of a RESP3 map or attribute
+type MapEntry struct {
+	Key   Value
+	Value Value
 }
 
 // The function Marshal in the provided code is likely named after the concept of marshaling,
@@ -18,120 +35,307 @@ type Value struct {
 // Marshaling refers to the process of converting data from one data structure into a format that can be easily stored, transmitted, or reconstructed in another data structure.
 // In this context, the Marshal function is responsible for converting a "Value" object into a byte representation that adheres to the RESP (REdis Serialization Protocol) format.
 func (v Value) Marshal() []byte {
+	bytes := v.AppendMarshal(nil)
+	if bytes == nil {
+		return []byte{}
+	}
+
+	return bytes
+}
+
+// AppendMarshal appends the RESP representation of the value to dst and returns the extended buffer,
+// passing a reused buffer lets hot paths encode replies without allocating
+// a value of unknown type appends nothing
+func (v Value) AppendMarshal(dst []byte) []byte {
+	if v.Attrs != nil {
+		dst = appendPairs(dst, ATTRIBUTE, v.Attrs)
+	}
+
 	switch v.Typ {
-	case "array":
-		return v.marshalArray()
-	case "bulk":
-		return v.marshalBulk()
-	case "string":
-		return v.marshalString()
-	case "int":
-		return v.marshalNum()
-	case "null":
-		return v.marshallNull()
-	case "error":
-		return v.marshallError()
+	case KindArray:
+		return v.appendAggregate(dst, ARRAY)
+	case KindBulk:
+		return AppendBulk(dst, v.Bulk)
+	case KindString:
+		return AppendSimpleString(dst, v.Str)
+	case KindInt, KindInteger:
+		if v.Str != "" && textIsInt(v.Str, v.Num) {
+			return appendLine(dst, INTEGER, v.Str)
+		}
+		return AppendInt(dst, v.Num)
+	case KindNull:
+		if v.Resp3Null {
+			return appendLine(dst, NULL, "")
+		}
+		return AppendNull(dst)
+	case KindNullArray:
+		return AppendNullArray(dst)
+	case KindError:
+		return AppendError(dst, v.Str)
+	case KindDouble:
+		if v.Str != "" && textIsDouble(v.Str, v.Double) {
+			return appendLine(dst, DOUBLE, v.Str)
+		}
+		return appendDouble(dst, v.Double)
+	case KindBoolean:
+		return appendBoolean(dst, v.Bool)
+	case KindBigNumber:
+		return appendLine(dst, BIGNUMBER, v.Str)
+	case KindVerbatim:
+		return appendVerbatim(dst, v.Format, v.Bulk)
+	case KindBlobError:
+		return appendBlob(dst, BLOBERROR, v.Str)
+	case KindMap:
+		return appendPairs(dst, MAP, v.Map)
+	case KindSet:
+		return v.appendAggregate(dst, SET)
+	case KindPush:
+		return v.appendAggregate(dst, PUSH)
 	default:
-		return []byte{}
+		return dst
 	}
 }
 
-func (v Value) marshalString() []byte {
-	var bytes []byte
-	bytes = append(bytes, STRING)
-	bytes = append(bytes, v.Str...)
-	bytes = append(bytes, '\r', '\n')
+// RespIo keeps the original text of integers and doubles in Str when it is not the one AppendInt or appendDouble would write,
+// it is only used while it still holds the number so a value whose Num or Double was changed is marshalled from the number
+func textIsInt(text string, n int64) bool {
+	if strings.ContainsAny(text, "\r\n") {
+		return false
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(text))
+	return err == nil && int64(parsed) == n
+}
 
-	return bytes
+func textIsDouble(text string, f float64) bool {
+	parsed, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return false
+	}
+	return parsed == f || (math.IsNaN(parsed) && math.IsNaN(f))
 }
 
-func (v Value) marshalNum() []byte {
+// Clone returns a deep copy of the value that shares no memory with it, use it to keep values returned by a ViewReader
+func (v Value) Clone() Value {
+	c := v
+	c.Str = strings.Clone(v.Str)
+	c.Bulk = strings.Clone(v.Bulk)
+	c.Format = strings.Clone(v.Format)
+	if v.Array != nil {
+		c.Array = make([]Value, len(v.Array))
+		for i, item := range v.Array {
+			c.Array[i] = item.Clone()
+		}
+	}
+	c.Map = cloneEntries(v.Map)
+	c.Attrs = cloneEntries(v.Attrs)
 
-	var bytes []byte
+	return c
+}
 
-	bytes = append(bytes, INTEGER)
-	bytes = append(bytes, strconv.FormatInt(int64(v.Num), 10)...)
-	bytes = append(bytes, '\r', '\n')
+func cloneEntries(entries []MapEntry) []MapEntry {
+	if entries == nil {
+		return nil
+	}
+	c := make([]MapEntry, len(entries))
+	for i, entry := range entries {
+		c[i] = MapEntry{Key: entry.Key.Clone(), Value: entry.Value.Clone()}
+	}
+	return c
+}
 
-	return bytes
+// IsNull reports whether the value is the null bulk string or the null array
+func (v Value) IsNull() bool {
+	return isNullValue(v)
 }
 
-func (v Value) marshalBulk() []byte {
-	var bytes []byte
-	bytes = append(bytes, BULK)
-	bytes = append(bytes, strconv.Itoa(len(v.Bulk))...)
-	bytes = append(bytes, '\r', '\n')
-	bytes = append(bytes, v.Bulk...)
-	bytes = append(bytes, '\r', '\n')
+// IsError reports whether the value is an error reply, simple or blob
+func (v Value) IsError() bool {
+	return v.Typ == KindError || v.Typ == KindBlobError
+}
 
-	return bytes
+// Err returns the error reply as a *ReplyError and nil for any other kind
+func (v Value) Err() error {
+	if !v.IsError() {
+		return nil
+	}
+	return &ReplyError{Message: v.Str}
 }
 
-func (v Value) marshalArray() []byte {
-	len := len(v.Array)
-	var bytes []byte
-	bytes = append(bytes, ARRAY)
-	bytes = append(bytes, strconv.Itoa(len)...)
-	bytes = append(bytes, '\r', '\n')
+// AsInt returns the integer of the value, bulk and simple strings holding a number are parsed like RESP2 servers send them
+func (v Value) AsInt() (int64, error) {
+	if n, ok := intOf(v); ok {
+		return n, nil
+	}
+	return 0, v.kindError("an integer")
+}
 
-	for i := 0; i < len; i++ {
-		bytes = append(bytes, v.Array[i].Marshal()...)
+// AsString returns the text of the string like values, integers and doubles are formatted
+func (v Value) AsString() (string, error) {
+	if text, ok := textOf(v); ok {
+		return text, nil
 	}
+	return "", v.kindError("a string")
+}
 
-	return bytes
+// AsArray returns the elements of an array, set or push, a map is flattened to its keys and values like RESP2 sends it
+func (v Value) AsArray() ([]Value, error) {
+	switch v.Typ {
+	case KindArray, KindSet, KindPush:
+		return v.Array, nil
+	case KindMap:
+		items := make([]Value, 0, 2*len(v.Map))
+		for _, entry := range v.Map {
+			items = append(items, entry.Key, entry.Value)
+		}
+		return items, nil
+	}
+	return nil, v.kindError("an array")
 }
 
-func (v Value) marshallError() []byte {
-	var bytes []byte
-	bytes = append(bytes, ERROR)
-	bytes = append(bytes, v.Str...)
-	bytes = append(bytes, '\r', '\n')
+// the error of an accessor that can't convert the value, error replies are returned as they are
+func (v Value) kindError(want string) error {
+	if v.IsError() {
+		return v.Err()
+	}
+	if v.IsNull() {
+		return ErrNil
+	}
+	return fmt.Errorf("%w: %s is not %s", ErrWrongKind, v.Typ, want)
+}
 
-	return bytes
+// appends the array like types (array, set, push) that only differ in the prefix
+func (v Value) appendAggregate(dst []byte, prefix byte) []byte {
+	dst = appendHeader(dst, prefix, len(v.Array))
+	for i := range v.Array {
+		dst = v.Array[i].AppendMarshal(dst)
+	}
+
+	return dst
 }
 
-func (v Value) marshallNull() []byte {
-	return []byte("$-1\r\n")
+// NewCommandValue builds the array of bulk strings a client sends for a command, for example NewCommandValue("GET", "key")
+func NewCommandValue(args ...string) Value {
+	arr := make([]Value, len(args))
+	for i, arg := range args {
+		arr[i] = Value{Typ: KindBulk, Bulk: arg}
+	}
+	val := Value{Typ: KindArray, Array: arr}
+
+	return val
 }
 
 func NewSetValue(key, value string) Value {
-	arr := []Value{{Typ: "bulk", Bulk: "set"}, {Typ: "bulk", Bulk: key}, {Typ: "bulk", Bulk: value}}
-	val := Value{Typ: "array", Array: arr}
+	arr := []Value{{Typ: KindBulk, Bulk: "set"}, {Typ: KindBulk, Bulk: key}, {Typ: KindBulk, Bulk: value}}
+	val := Value{Typ: KindArray, Array: arr}
 
 	return val
 }
 
 func NewHsetValue(hash, key, value string) Value {
-	fmt.Println(hash)
-	arr := []Value{{Typ: "bulk", Bulk: "hset"}, {Typ: "bulk", Bulk: hash}, {Typ: "bulk", Bulk: key}, {Typ: "bulk", Bulk: value}}
-	val := Value{Typ: "array", Array: arr}
+	arr := []Value{{Typ: KindBulk, Bulk: "hset"}, {Typ: KindBulk, Bulk: hash}, {Typ: KindBulk, Bulk: key}, {Typ: KindBulk, Bulk: value}}
+	val := Value{Typ: KindArray, Array: arr}
 
 	return val
 }
 
 func NewDelValue(keys []string) Value {
-	arr := []Value{{Typ: "bulk", Bulk: "del"}}
+	arr := []Value{{Typ: KindBulk, Bulk: "del"}}
 
 	for _, key := range keys {
-		v := Value{Typ: "bulk", Bulk: key}
+		v := Value{Typ: KindBulk, Bulk: key}
 
 		arr = append(arr, v)
 	}
-	val := Value{Typ: "array", Array: arr}
+	val := Value{Typ: KindArray, Array: arr}
 
 	return val
 }
 
 func NewErrorValue(message string) Value {
 
-	val := Value{Typ: "error", Str: message}
+	val := Value{Typ: KindError, Str: message}
 
 	return val
 }
 
 func NewNumberValue(number int64) Value {
 
-	val := Value{Typ: "int", Num: number}
+	val := Value{Typ: KindInt, Num: number}
+
+	return val
+}
+
+// NewNullValue builds the null bulk string $-1 that redis replies for a missing key
+func NewNullValue() Value {
+
+	val := Value{Typ: KindNull}
+
+	return val
+}
+
+// NewNullArrayValue builds the null array *-1 that redis replies for example when a blocking pop times out
+func NewNullArrayValue() Value {
+
+	val := Value{Typ: KindNullArray}
+
+	return val
+}
+
+func NewDoubleValue(number float64) Value {
+
+	val := Value{Typ: KindDouble, Double: number}
+
+	return val
+}
+
+func NewBooleanValue(b bool) Value {
+
+	val := Value{Typ: KindBoolean, Bool: b}
+
+	return val
+}
+
+// NewBigNumberValue takes the decimal digits of the number, an optional leading sign is allowed
+func NewBigNumberValue(digits string) Value {
+
+	val := Value{Typ: KindBigNumber, Str: digits}
+
+	return val
+}
+
+// NewVerbatimValue takes a 3 bytes format like "txt" or "mkd" and the text, any other format is marshalled as "txt"
+func NewVerbatimValue(format, text string) Value {
+
+	val := Value{Typ: KindVerbatim, Format: format, Bulk: text}
+
+	return val
+}
+
+func NewBlobErrorValue(message string) Value {
+
+	val := Value{Typ: KindBlobError, Str: message}
+
+	return val
+}
+
+func NewMapValue(entries []MapEntry) Value {
+
+	val := Value{Typ: KindMap, Map: entries}
+
+	return val
+}
+
+// NewRespSetValue builds a RESP3 set reply, not to be confused with NewSetValue which builds the SET command
+func NewRespSetValue(members []Value) Value {
+
+	val := Value{Typ: KindSet, Array: members}
+
+	return val
+}
+
+func NewPushValue(items []Value) Value {
+
+	val := Value{Typ: KindPush, Array: items}
 
 	return val
 }
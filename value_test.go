@@ -2,8 +2,13 @@ package goresp
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -59,6 +64,24 @@ func TestValue_Marshal_Null(t *testing.T) {
 	}
 }
 
+func TestValue_Marshal_NullArray(t *testing.T) {
+	v := NewNullArrayValue()
+	expected := []byte("*-1\r\n")
+	result := v.Marshal()
+	if !bytes.Equal(result, expected) {
+		t.Errorf("Marshal() for null array returned %q, want %q", result, expected)
+	}
+}
+
+func TestNewNullValue(t *testing.T) {
+	v := NewNullValue()
+	expected := []byte("$-1\r\n")
+	result := v.Marshal()
+	if !bytes.Equal(result, expected) {
+		t.Errorf("Marshal() for null bulk returned %q, want %q", result, expected)
+	}
+}
+
 func TestValue_Marshal_Integer(t *testing.T) {
 	v := Value{
 		Typ: "int",
@@ -163,24 +186,10 @@ func TestValue_Marshal_Consistency(t *testing.T) {
 				t.Errorf("Marshal() returned %v, want %v", result, tc.expected)
 			}
 
-			var specificResult []byte
-			switch tc.value.Typ {
-			case "array":
-				specificResult = tc.value.marshalArray()
-			case "bulk":
-				specificResult = tc.value.marshalBulk()
-			case "string":
-				specificResult = tc.value.marshalString()
-			case "int":
-				specificResult = tc.value.marshalNum()
-			case "null":
-				specificResult = tc.value.marshallNull()
-			case "error":
-				specificResult = tc.value.marshallError()
-			}
-
-			if !bytes.Equal(result, specificResult) {
-				t.Errorf("Marshal() and specific marshal function returned different results. Marshal: %v, Specific: %v", result, specificResult)
+			// the encoder Marshal is built on appends after what the buffer already holds
+			appended := tc.value.AppendMarshal([]byte("prefix"))
+			if !bytes.Equal(appended, append([]byte("prefix"), result...)) {
+				t.Errorf("Marshal() and AppendMarshal() returned different results. Marshal: %v, AppendMarshal: %v", result, appended)
 			}
 		})
 	}
@@ -657,3 +666,296 @@ func TestNewDelValue_AlwaysReturnsArrayType(t *testing.T) {
 		})
 	}
 }
+
+func TestValue_Marshal_RESP3(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    Value
+		expected string
+	}{
+		{"Double", NewDoubleValue(1.5), ",1.5\r\n"},
+		{"DoubleInteger", NewDoubleValue(10), ",10\r\n"},
+		{"DoubleInf", NewDoubleValue(math.Inf(1)), ",inf\r\n"},
+		{"DoubleNegativeInf", NewDoubleValue(math.Inf(-1)), ",-inf\r\n"},
+		{"DoubleNaN", NewDoubleValue(math.NaN()), ",nan\r\n"},
+		{"BooleanTrue", NewBooleanValue(true), "#t\r\n"},
+		{"BooleanFalse", NewBooleanValue(false), "#f\r\n"},
+		{"BigNumber", NewBigNumberValue("123456789012345678901234567890"), "(123456789012345678901234567890\r\n"},
+		{"Verbatim", NewVerbatimValue("txt", "Some string"), "=15\r\ntxt:Some string\r\n"},
+		{"BlobError", NewBlobErrorValue("SYNTAX invalid syntax"), "!21\r\nSYNTAX invalid syntax\r\n"},
+		{"Map", NewMapValue([]MapEntry{{Key: Value{Typ: "string", Str: "a"}, Value: NewNumberValue(1)}}), "%1\r\n+a\r\n:1\r\n"},
+		{"Set", NewRespSetValue([]Value{{Typ: "bulk", Bulk: "x"}}), "~1\r\n$1\r\nx\r\n"},
+		{"Push", NewPushValue([]Value{{Typ: "bulk", Bulk: "message"}}), ">1\r\n$7\r\nmessage\r\n"},
+		{"Attribute", Value{Typ: "int", Num: 7, Attrs: []MapEntry{{Key: Value{Typ: "string", Str: "ttl"}, Value: NewNumberValue(1)}}}, "|1\r\n+ttl\r\n:1\r\n:7\r\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := tc.value.Marshal()
+			if string(result) != tc.expected {
+				t.Errorf("Marshal() returned %q, want %q", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestValue_Clone(t *testing.T) {
+	v := Value{
+		Typ:   "array",
+		Array: []Value{{Typ: "bulk", Bulk: "foo"}},
+		Map:   []MapEntry{{Key: Value{Typ: "string", Str: "k"}, Value: Value{Typ: "bulk", Bulk: "v"}}},
+	}
+
+	c := v.Clone()
+
+	if !reflect.DeepEqual(c, v) {
+		t.Fatalf("Clone() = %+v, want %+v", c, v)
+	}
+
+	c.Array[0].Bulk = "changed"
+	c.Map[0].Value.Bulk = "changed"
+	if v.Array[0].Bulk != "foo" || v.Map[0].Value.Bulk != "v" {
+		t.Errorf("Clone() shares memory with the original value")
+	}
+}
+
+func TestNewCommandValue(t *testing.T) {
+	result := NewCommandValue("GET", "key")
+	expected := Value{Typ: "array", Array: []Value{{Typ: "bulk", Bulk: "GET"}, {Typ: "bulk", Bulk: "key"}}}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("NewCommandValue() = %+v, want %+v", result, expected)
+	}
+}
+
+func TestValue_Marshal_LegacyInteger(t *testing.T) {
+	v := Value{Typ: KindInteger, Num: 7}
+
+	if got := string(v.Marshal()); got != ":7\r\n" {
+		t.Errorf("Marshal() for the legacy integer kind returned %q, want %q", got, ":7\r\n")
+	}
+}
+
+func TestValue_Accessors(t *testing.T) {
+	if !NewNullValue().IsNull() || !NewNullArrayValue().IsNull() || NewNumberValue(0).IsNull() {
+		t.Error("IsNull() is wrong")
+	}
+	if !NewErrorValue("ERR x").IsError() || !NewBlobErrorValue("ERR x").IsError() || NewCommandValue().IsError() {
+		t.Error("IsError() is wrong")
+	}
+	if NewNumberValue(1).Err() != nil {
+		t.Error("Err() of an integer should be nil")
+	}
+
+	n, err := NewNumberValue(42).AsInt()
+	if err != nil || n != 42 {
+		t.Errorf("AsInt() = %d, %v, want 42", n, err)
+	}
+	n, err = Value{Typ: KindBulk, Bulk: "-3"}.AsInt()
+	if err != nil || n != -3 {
+		t.Errorf("AsInt() of a numeric bulk = %d, %v, want -3", n, err)
+	}
+	if _, err := (Value{Typ: KindBulk, Bulk: "abc"}).AsInt(); !errors.Is(err, ErrWrongKind) {
+		t.Errorf("AsInt() of a non numeric bulk returned %v, want ErrWrongKind", err)
+	}
+
+	s, err := Value{Typ: KindBulk, Bulk: "foo"}.AsString()
+	if err != nil || s != "foo" {
+		t.Errorf("AsString() = %q, %v, want foo", s, err)
+	}
+	s, err = NewNumberValue(7).AsString()
+	if err != nil || s != "7" {
+		t.Errorf("AsString() of an integer = %q, %v, want 7", s, err)
+	}
+	if _, err := NewNullValue().AsString(); !errors.Is(err, ErrNil) {
+		t.Errorf("AsString() of null returned %v, want ErrNil", err)
+	}
+	if _, err := NewCommandValue("a").AsString(); !errors.Is(err, ErrWrongKind) {
+		t.Errorf("AsString() of an array returned %v, want ErrWrongKind", err)
+	}
+
+	var replyErr *ReplyError
+	if _, err := NewErrorValue("WRONGTYPE Operation against a key").AsString(); !errors.As(err, &replyErr) || replyErr.Prefix() != "WRONGTYPE" {
+		t.Errorf("AsString() of an error reply returned %v, want a *ReplyError", err)
+	}
+
+	items, err := NewCommandValue("a", "b").AsArray()
+	if err != nil || len(items) != 2 {
+		t.Errorf("AsArray() = %v, %v, want 2 items", items, err)
+	}
+	items, err = NewMapValue([]MapEntry{{Key: NewNumberValue(1), Value: NewNumberValue(2)}}).AsArray()
+	if err != nil || !reflect.DeepEqual(items, []Value{NewNumberValue(1), NewNumberValue(2)}) {
+		t.Errorf("AsArray() of a map = %v, %v, want the flattened pairs", items, err)
+	}
+	if _, err := NewNullArrayValue().AsArray(); !errors.Is(err, ErrNil) {
+		t.Errorf("AsArray() of the null array returned %v, want ErrNil", err)
+	}
+}
+
+// builds a random value tree of every kind the readers support
+func randomValue(rnd *rand.Rand, depth int) Value {
+	kinds := []Kind{KindString, KindError, KindInt, KindBulk, KindNull, KindNullArray, KindDouble, KindBoolean, KindBigNumber, KindVerbatim, KindBlobError}
+	if depth > 0 {
+		kinds = append(kinds, KindArray, KindMap, KindSet, KindPush)
+	}
+
+	var v Value
+	switch kind := kinds[rnd.IntN(len(kinds))]; kind {
+	case KindString:
+		v = Value{Typ: KindString, Str: randomLine(rnd)}
+	case KindError:
+		v = NewErrorValue(randomLine(rnd))
+	case KindInt:
+		n := rnd.Int64() >> rnd.IntN(64)
+		if rnd.IntN(2) == 0 {
+			n = -n
+		}
+		v = NewNumberValue(n)
+	case KindBulk:
+		v = Value{Typ: KindBulk, Bulk: randomBytes(rnd)}
+	case KindNull:
+		v = NewNullValue()
+		v.Resp3Null = rnd.IntN(2) == 0
+	case KindNullArray:
+		v = NewNullArrayValue()
+	case KindDouble:
+		v = NewDoubleValue(randomDouble(rnd))
+	case KindBoolean:
+		v = NewBooleanValue(rnd.IntN(2) == 0)
+	case KindBigNumber:
+		v = NewBigNumberValue("-" + strconv.FormatUint(rnd.Uint64(), 10) + strconv.FormatUint(rnd.Uint64(), 10))
+	case KindVerbatim:
+		v = NewVerbatimValue("txt", randomBytes(rnd))
+	case KindBlobError:
+		v = NewBlobErrorValue(randomBytes(rnd))
+	case KindArray, KindSet, KindPush:
+		items := make([]Value, rnd.IntN(4))
+		for i := range items {
+			items[i] = randomValue(rnd, depth-1)
+		}
+		v = Value{Typ: kind, Array: items}
+	case KindMap:
+		v = NewMapValue(randomEntries(rnd, depth-1))
+	}
+
+	if depth > 0 && rnd.IntN(8) == 0 {
+		v.Attrs = randomEntries(rnd, depth-1)
+	}
+	return v
+}
+
+func randomEntries(rnd *rand.Rand, depth int) []MapEntry {
+	entries := make([]MapEntry, rnd.IntN(3))
+	for i := range entries {
+		entries[i] = MapEntry{Key: randomValue(rnd, depth), Value: randomValue(rnd, depth)}
+	}
+	return entries
+}
+
+// a line without CR or LF for simple strings and errors
+func randomLine(rnd *rand.Rand) string {
+	b := make([]byte, rnd.IntN(16))
+	for i := range b {
+		b[i] = byte(' ' + rnd.IntN('~'-' '+1))
+	}
+	return string(b)
+}
+
+// any bytes, CRLF included, for the length prefixed types
+func randomBytes(rnd *rand.Rand) string {
+	b := make([]byte, rnd.IntN(16))
+	for i := range b {
+		b[i] = byte(rnd.IntN(256))
+	}
+	return string(b)
+}
+
+func randomDouble(rnd *rand.Rand) float64 {
+	switch rnd.IntN(6) {
+	case 0:
+		return math.Inf(1)
+	case 1:
+		return math.Inf(-1)
+	case 2:
+		return math.NaN()
+	case 3:
+		return math.Copysign(0, -1)
+	default:
+		return rnd.NormFloat64() * math.Pow(10, float64(rnd.IntN(40)-20))
+	}
+}
+
+func TestValue_Marshal_RandomRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 2000; i++ {
+		v := randomValue(rnd, 3)
+		wire := v.Marshal()
+
+		for _, reader := range []RespReader{NewRespIo(bytes.NewReader(wire)), NewViewReader(bytes.NewReader(wire))} {
+			result, err := reader.Read()
+			if err != nil {
+				t.Fatalf("Read() of %q returned error %v", wire, err)
+			}
+			if got := result.Marshal(); !bytes.Equal(got, wire) {
+				t.Fatalf("Marshal() after Read() = %q, want %q", got, wire)
+			}
+		}
+	}
+}
+
+// a rand.Source that draws from the fuzzer input so the mutations of the input change the shape of the value
+// once the input is used up it goes on with a fixed sequence, a source stuck on zero makes rand loop forever
+type byteSource struct {
+	data []byte
+	n    uint64
+}
+
+func (s *byteSource) Uint64() uint64 {
+	if len(s.data) == 0 {
+		s.n++
+		return s.n * 0x9e3779b97f4a7c15
+	}
+	var buf [8]byte
+	n := copy(buf[:], s.data)
+	s.data = s.data[n:]
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func FuzzValue_Marshal(f *testing.F) {
+	f.Add([]byte{}, "OK", "txt")
+	f.Add([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9}, "ERR wrong\r\nnumber", "mkd")
+	f.Add([]byte("some longer seed to build nested values"), "", "")
+	f.Add([]byte{0xff, 0x10, 0x80}, "\n", "toolong")
+
+	f.Fuzz(func(t *testing.T, data []byte, text, format string) {
+		v := Value{Typ: KindArray, Array: []Value{
+			randomValue(rand.New(&byteSource{data: data}), 4),
+			{Typ: KindString, Str: text},
+			NewErrorValue(text),
+			NewBlobErrorValue(text),
+			{Typ: KindBulk, Bulk: text},
+			NewVerbatimValue(format, text),
+			{Typ: KindInt, Num: int64(len(text)), Str: text},
+			{Typ: KindDouble, Double: float64(len(text)), Str: text},
+		}}
+
+		wire := v.Marshal()
+		if prefixed := v.AppendMarshal([]byte("prefix")); !bytes.Equal(prefixed, append([]byte("prefix"), wire...)) {
+			t.Fatalf("AppendMarshal() = %q, want the prefix followed by %q", prefixed, wire)
+		}
+
+		// whatever the value holds Marshal must write a single valid value that reads back to the same bytes
+		reader := NewRespIo(bytes.NewReader(wire))
+		result, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Read() of the marshalled %q returned error %v", wire, err)
+		}
+		if reader.Offset() != int64(len(wire)) {
+			t.Fatalf("Read() of %q consumed %d bytes, want %d", wire, reader.Offset(), len(wire))
+		}
+		if got := result.Marshal(); !bytes.Equal(got, wire) {
+			t.Fatalf("Marshal() after Read() = %q, want %q", got, wire)
+		}
+	})
+}